@@ -1,3 +1,15 @@
 module github.com/lucianoayres/taco
 
 go 1.23.2
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/pkoukk/tiktoken-go v0.1.8
+	github.com/pkoukk/tiktoken-go-loader v0.0.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+)
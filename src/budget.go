@@ -0,0 +1,237 @@
+// File: src/budget.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// rankRules lists the supported values of the -rank flag.
+var rankRules = []string{"include-order", "depth", "recency", "size"}
+
+// isRankRule reports whether rule is a supported ranking rule.
+func isRankRule(rule string) bool {
+	return slices.Contains(rankRules, rule)
+}
+
+// droppedFile is a file left out of the output because it did not fit the budget.
+type droppedFile struct {
+	relativePath string
+	reason       string
+}
+
+// fileCost is the number of bytes and tokens a file takes in the output, including its header.
+type fileCost struct {
+	bytes  int64
+	tokens int
+}
+
+// applyBudget keeps the highest-ranked files whose rendered output fits within -max-tokens and -max-bytes.
+// Files are ranked with the -rank rules, then added greedily: a file that does not fit is dropped, but smaller
+// files ranked after it may still be included. With -tree, room is kept for the directory tree of the kept
+// files. The kept files are returned in walk order.
+func applyBudget(files []loadedFile, opts *options, tokenizer tokenizer) ([]loadedFile, []droppedFile, error) {
+	counter := tokenizer
+	if opts.maxTokens == 0 {
+		counter = nil
+	}
+	measure, err := newCostMeasurer(opts, counter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The header and footer are written once whatever files are kept
+	var remaining fileCost
+	overhead := measure.overhead()
+	remaining.bytes = opts.maxBytes - overhead.bytes
+	remaining.tokens = opts.maxTokens - overhead.tokens
+
+	// The tree of every file is reserved up front, as the tree of the kept files is known only once they are chosen
+	var reserved fileCost
+	if opts.tree && len(files) > 0 {
+		reserved = measure.tree(buildTree(files, opts, tokenizer))
+	}
+	remaining.bytes -= reserved.bytes
+	remaining.tokens -= reserved.tokens
+
+	ranked := make([]int, len(files))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return compareCandidates(files[ranked[i]].fileCandidate, files[ranked[j]].fileCandidate, opts.rankRules) < 0
+	})
+
+	keep := make([]bool, len(files))
+	costs := make([]fileCost, len(files))
+	var kept []int
+	var dropped []droppedFile
+	for _, i := range ranked {
+		file := files[i]
+		cost, err := measure.entry(file.entry())
+		if err != nil {
+			return nil, nil, err
+		}
+		if reason := exceedsBudget(cost, remaining, opts); reason != "" {
+			dropped = append(dropped, droppedFile{file.relativePath, reason})
+			continue
+		}
+		remaining.bytes -= cost.bytes
+		remaining.tokens -= cost.tokens
+		keep[i], costs[i] = true, cost
+		kept = append(kept, i)
+	}
+
+	selected := func() []loadedFile {
+		var selected []loadedFile
+		for i, file := range files {
+			if keep[i] {
+				selected = append(selected, file)
+			}
+		}
+		return selected
+	}
+
+	// The tree of the kept files is usually smaller than the one reserved, but its sizes may be written
+	// with more digits, so the lowest-ranked files are dropped until it fits
+	for opts.tree && len(kept) > 0 {
+		tree := measure.tree(buildTree(selected(), opts, tokenizer))
+		remaining.bytes += reserved.bytes - tree.bytes
+		remaining.tokens += reserved.tokens - tree.tokens
+		reserved = tree
+		if (opts.maxBytes == 0 || remaining.bytes >= 0) && (opts.maxTokens == 0 || remaining.tokens >= 0) {
+			break
+		}
+		last := kept[len(kept)-1]
+		kept = kept[:len(kept)-1]
+		keep[last] = false
+		remaining.bytes += costs[last].bytes
+		remaining.tokens += costs[last].tokens
+		dropped = append(dropped, droppedFile{files[last].relativePath, "no room left for the directory tree"})
+	}
+	return selected(), dropped, nil
+}
+
+// exceedsBudget returns why a file of the given cost does not fit in what remains of the budget, or "" if it fits.
+func exceedsBudget(cost, remaining fileCost, opts *options) string {
+	if opts.maxTokens > 0 && cost.tokens > remaining.tokens {
+		return fmt.Sprintf("needs %d tokens but only %d of %d remain", cost.tokens, max(remaining.tokens, 0), opts.maxTokens)
+	}
+	if opts.maxBytes > 0 && cost.bytes > remaining.bytes {
+		return fmt.Sprintf("needs %d bytes but only %d of %d remain", cost.bytes, max(remaining.bytes, 0), opts.maxBytes)
+	}
+	return ""
+}
+
+// compareCandidates orders two files by the ranking rules, applied in order until one tells them apart.
+// It returns a negative number when a ranks before b.
+func compareCandidates(a, b fileCandidate, rules []string) int {
+	for _, rule := range rules {
+		var result int
+		switch rule {
+		case "include-order":
+			// Files from earlier include directories, then from earlier include patterns, come first
+			result = a.root - b.root
+			if result == 0 {
+				result = a.pattern - b.pattern
+			}
+		case "depth":
+			// Shallower files come first
+			result = pathDepth(a.relativePath) - pathDepth(b.relativePath)
+		case "recency":
+			// Recently modified files come first
+			result = b.modTime.Compare(a.modTime)
+		case "size":
+			// Smaller files come first
+			result = compareInt64(a.size, b.size)
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// compareInt64 returns -1, 0 or 1 depending on whether a is less than, equal to or greater than b.
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// pathDepth returns the number of directories above a relative path.
+func pathDepth(relativePath string) int {
+	return strings.Count(filepath.ToSlash(filepath.Clean(relativePath)), "/")
+}
+
+// costMeasurer renders files with their own formatter instance to measure what they would add to the output.
+// Tokens are only counted when it has a tokenizer.
+type costMeasurer struct {
+	formatter outputFormatter
+	tokenizer tokenizer
+}
+
+func newCostMeasurer(opts *options, tokenizer tokenizer) (*costMeasurer, error) {
+	formatter, err := newFormatter(opts.format, opts.templateFile)
+	if err != nil {
+		return nil, err
+	}
+	return &costMeasurer{formatter: formatter, tokenizer: tokenizer}, nil
+}
+
+// overhead returns the cost of the header and footer of the output.
+func (m *costMeasurer) overhead() fileCost {
+	var buf bytes.Buffer
+	m.formatter.writeHeader(&buf)
+	cost := m.cost(buf.Bytes())
+	buf.Reset()
+	m.formatter.writeFooter(&buf)
+	footer := m.cost(buf.Bytes())
+	cost.bytes += footer.bytes
+	cost.tokens += footer.tokens
+	return cost
+}
+
+// tree returns the cost of the directory tree.
+func (m *costMeasurer) tree(tree string) fileCost {
+	var buf bytes.Buffer
+	m.formatter.writeTree(&buf, tree)
+	return m.cost(buf.Bytes())
+}
+
+// entry returns the cost of a file entry as it would be written to the output.
+func (m *costMeasurer) entry(file fileEntry) (fileCost, error) {
+	var buf bytes.Buffer
+	if err := m.formatter.writeFile(&buf, file); err != nil {
+		return fileCost{}, err
+	}
+	return m.cost(buf.Bytes()), nil
+}
+
+func (m *costMeasurer) cost(rendered []byte) fileCost {
+	cost := fileCost{bytes: int64(len(rendered))}
+	if m.tokenizer != nil {
+		cost.tokens = m.tokenizer.countTokens(string(rendered))
+	}
+	return cost
+}
+
+// printDroppedFiles lists the files left out of the output and why.
+func printDroppedFiles(opts *options, dropped []droppedFile) {
+	if len(dropped) == 0 {
+		return
+	}
+	opts.logf("Dropped %d files to fit the budget:\n", len(dropped))
+	for _, file := range dropped {
+		opts.logf("  %s: %s\n", filepath.ToSlash(file.relativePath), file.reason)
+	}
+}
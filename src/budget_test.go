@@ -0,0 +1,112 @@
+// File: src/budget_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompareCandidates checks that ranking rules are applied in order until one tells two files apart.
+func TestCompareCandidates(t *testing.T) {
+	now := time.Now()
+	shallow := fileCandidate{relativePath: "main.go", root: 1, size: 500, modTime: now.Add(-time.Hour)}
+	deep := fileCandidate{relativePath: "src/pkg/util.go", root: 0, size: 100, modTime: now}
+
+	tests := []struct {
+		rules    []string
+		expected int // Sign of the comparison of shallow with deep
+	}{
+		{[]string{"include-order"}, 1},
+		{[]string{"depth"}, -1},
+		{[]string{"recency"}, 1},
+		{[]string{"size"}, 1},
+		{[]string{"depth", "size"}, -1},
+		{nil, 0},
+	}
+
+	for _, test := range tests {
+		result := compareCandidates(shallow, deep, test.rules)
+		if (result < 0 && test.expected >= 0) || (result > 0 && test.expected <= 0) || (result == 0 && test.expected != 0) {
+			t.Errorf("Rules %v: expected comparison sign %d, got %d", test.rules, test.expected, result)
+		}
+	}
+}
+
+// TestConcatenateFilesWithBudget checks that files that do not fit the budget are dropped, smaller
+// lower-ranked files are still included and the kept files are written in walk order.
+func TestConcatenateFilesWithBudget(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.WriteFile(filepath.Join(parentDir, "a.txt"), []byte(strings.Repeat("a", 40)), 0644)
+	os.WriteFile(filepath.Join(parentDir, "b.txt"), []byte(strings.Repeat("b", 100)), 0644)
+	os.WriteFile(filepath.Join(parentDir, "c.txt"), []byte(strings.Repeat("c", 10)), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	// Each file costs its size plus 17 bytes of header and separator: 57, 117 and 27 bytes
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		maxBytes:       100,
+		rankRules:      []string{"include-order", "depth"},
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: a.txt\n\n" + strings.Repeat("a", 40) + "\n// File: c.txt\n\n" + strings.Repeat("c", 10) + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestApplyBudgetReasons checks that dropped files are reported with the remaining budget.
+func TestApplyBudgetReasons(t *testing.T) {
+	opts := &options{maxTokens: 10, tokenizerName: "estimate", rankRules: []string{"size"}}
+	files := []loadedFile{{fileCandidate: fileCandidate{relativePath: "big.txt", size: 200}, content: []byte(strings.Repeat("x", 200))}}
+	selected, dropped, err := applyBudget(files, opts, estimateTokenizer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(selected) != 0 {
+		t.Errorf("Expected no files to be selected, got %d", len(selected))
+	}
+	if len(dropped) != 1 || !strings.Contains(dropped[0].reason, "only 10 of 10 remain") {
+		t.Errorf("Expected big.txt to be dropped with the remaining budget, got %v", dropped)
+	}
+}
+
+// TestConcatenateFilesWithBudgetAndTree checks that the directory tree is counted in the budget.
+func TestConcatenateFilesWithBudgetAndTree(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.WriteFile(filepath.Join(parentDir, "a.txt"), []byte(strings.Repeat("a", 40)), 0644)
+	os.WriteFile(filepath.Join(parentDir, "c.txt"), []byte(strings.Repeat("c", 10)), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	// The files cost 57 and 27 bytes and their tree 54 bytes, so both files fit in 120 bytes but not with the tree
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		maxBytes:       120,
+		rankRules:      []string{"include-order", "depth"},
+		tree:           true,
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	if len(data) > 120 {
+		t.Errorf("Expected at most 120 bytes, got %d:\n%s", len(data), data)
+	}
+	if !strings.Contains(string(data), "a.txt") || strings.Contains(string(data), "// File: c.txt") {
+		t.Errorf("Expected the tree and a.txt only, got:\n%s", data)
+	}
+}
@@ -0,0 +1,455 @@
+// File: src/config.go
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames lists the project configuration files looked up in each directory, in order of preference.
+var configFileNames = []string{"taco.yaml", "taco.yml", "taco.toml"}
+
+// Config holds every option taco accepts. Each field is keyed by the name of its command-line flag,
+// both in the configuration file and when merging explicitly set flags over it.
+type Config struct {
+	Output             string       `yaml:"output" toml:"output"`
+	Stdout             bool         `yaml:"stdout" toml:"stdout"`
+	Mode               string       `yaml:"mode" toml:"mode"`
+	Format             string       `yaml:"format" toml:"format"`
+	Template           string       `yaml:"template" toml:"template"`
+	IncludeExt         []string     `yaml:"include-ext" toml:"include-ext"`
+	ExcludeExt         []string     `yaml:"exclude-ext" toml:"exclude-ext"`
+	TextExt            []string     `yaml:"text-ext" toml:"text-ext"`
+	BinaryExt          []string     `yaml:"binary-ext" toml:"binary-ext"`
+	SampleSize         int          `yaml:"sample-size" toml:"sample-size"`
+	EOL                string       `yaml:"eol" toml:"eol"`
+	MaxFileSize        int64        `yaml:"max-file-size" toml:"max-file-size"`
+	MaxFileLines       int          `yaml:"max-file-lines" toml:"max-file-lines"`
+	Oversized          string       `yaml:"oversized" toml:"oversized"`
+	TailLines          int          `yaml:"tail-lines" toml:"tail-lines"`
+	AllowSensitive     bool         `yaml:"allow-sensitive" toml:"allow-sensitive"`
+	NoRedact           bool         `yaml:"no-redact" toml:"no-redact"`
+	FailOnSecrets      bool         `yaml:"fail-on-secrets" toml:"fail-on-secrets"`
+	SecretRules        []SecretRule `yaml:"secret-rules" toml:"secret-rules"`
+	IncludeDir         []string     `yaml:"include-dir" toml:"include-dir"`
+	ExcludeDir         []string     `yaml:"exclude-dir" toml:"exclude-dir"`
+	NoDefaultExcludes  bool         `yaml:"no-default-excludes" toml:"no-default-excludes"`
+	Hidden             bool         `yaml:"hidden" toml:"hidden"`
+	FollowSymlinks     string       `yaml:"follow-symlinks" toml:"follow-symlinks"`
+	IncludeFilePattern []string     `yaml:"include-file-pattern" toml:"include-file-pattern"`
+	ExcludeFilePattern []string     `yaml:"exclude-file-pattern" toml:"exclude-file-pattern"`
+	IncludePath        []string     `yaml:"include-path" toml:"include-path"`
+	ExcludePath        []string     `yaml:"exclude-path" toml:"exclude-path"`
+	CountTokens        bool         `yaml:"count-tokens" toml:"count-tokens"`
+	Tokenizer          string       `yaml:"tokenizer" toml:"tokenizer"`
+	MaxTokens          int          `yaml:"max-tokens" toml:"max-tokens"`
+	MaxBytes           int64        `yaml:"max-bytes" toml:"max-bytes"`
+	Rank               []string     `yaml:"rank" toml:"rank"`
+	SplitBytes         int64        `yaml:"split-bytes" toml:"split-bytes"`
+	SplitTokens        int          `yaml:"split-tokens" toml:"split-tokens"`
+	SplitFiles         int          `yaml:"split-files" toml:"split-files"`
+	Tree               bool         `yaml:"tree" toml:"tree"`
+	TreeOnly           bool         `yaml:"tree-only" toml:"tree-only"`
+	TreeSizes          bool         `yaml:"tree-sizes" toml:"tree-sizes"`
+	TreeTokens         bool         `yaml:"tree-tokens" toml:"tree-tokens"`
+	Jobs               int          `yaml:"jobs" toml:"jobs"`
+	Sort               string       `yaml:"sort" toml:"sort"`
+	Priority           []string     `yaml:"priority" toml:"priority"`
+	Verbose            bool         `yaml:"verbose" toml:"verbose"`
+	NoIgnoreFiles      bool         `yaml:"no-ignore-files" toml:"no-ignore-files"`
+
+	// Run-only settings that never come from a configuration file
+	ConfigFile  string `yaml:"-" toml:"-"`
+	Profile     string `yaml:"-" toml:"-"`
+	PrintConfig bool   `yaml:"-" toml:"-"`
+}
+
+// defaultConfig returns the configuration used when neither flags nor a configuration file set an option.
+func defaultConfig() Config {
+	return Config{
+		Output:         "taco.txt",
+		Mode:           "overwrite",
+		Format:         "plain",
+		Tokenizer:      "o200k",
+		FollowSymlinks: "files",
+		SampleSize:     defaultSampleSize,
+		EOL:            "keep",
+		Oversized:      "truncate",
+		Rank:           []string{"include-order", "depth", "size"},
+	}
+}
+
+// listValue is a flag.Value that parses a comma-separated list into a string slice.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		trimmedItem := strings.TrimSpace(item)
+		if trimmedItem != "" {
+			items = append(items, trimmedItem)
+		}
+	}
+	return items
+}
+
+// findConfigFile walks up from dir and returns the first project configuration file found, or "" if there is none.
+func findConfigFile(dir string) string {
+	for {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// profileOptions is the body of a named profile: any option, plus the name of the profile it extends.
+type profileOptions struct {
+	Config  `yaml:",inline"`
+	Extends string `yaml:"extends" toml:"extends"`
+}
+
+// profileLayer is a profile read from the configuration file. apply decodes only the options the profile sets over cfg.
+type profileLayer struct {
+	extends string
+	apply   func(cfg *Config) error
+}
+
+// loadConfigFile decodes a YAML or TOML configuration file over cfg. Only the options present in the file are changed.
+// When profile is not empty, the named profile and the profiles it extends are then applied, from the most general one down.
+// Unknown keys are reported as errors so that typos do not go unnoticed. Paths in the file are relative to its directory.
+func loadConfigFile(path string, cfg *Config, profile string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+	configDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("error resolving directory of config file %s: %v", path, err)
+	}
+
+	// The default output stays relative to the working directory unless the file replaces it
+	defaultOutput := cfg.Output
+	cfg.Output = ""
+	defer func() {
+		rebaseConfigPaths(cfg, configDir)
+		if cfg.Output == "" {
+			cfg.Output = defaultOutput
+		}
+	}()
+
+	var profiles map[string]profileLayer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		profiles, err = decodeTOMLConfig(data, cfg)
+	case ".yaml", ".yml":
+		profiles, err = decodeYAMLConfig(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format %s: expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+
+	if profile == "" {
+		return nil
+	}
+	chain, err := resolveProfile(profiles, profile)
+	if err != nil {
+		return fmt.Errorf("%v in config file %s", err, path)
+	}
+	for _, layer := range chain {
+		if err := layer.apply(cfg); err != nil {
+			return fmt.Errorf("error parsing config file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// resolveProfile returns the named profile preceded by every profile it extends, most general first.
+func resolveProfile(profiles map[string]profileLayer, name string) ([]profileLayer, error) {
+	var chain []profileLayer
+	visited := make(map[string]bool)
+	for current := name; current != ""; {
+		layer, ok := profiles[current]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q (available profiles: %s)", current, availableProfiles(profiles))
+		}
+		if visited[current] {
+			return nil, fmt.Errorf("profile %q extends itself through a cycle", current)
+		}
+		visited[current] = true
+		chain = append([]profileLayer{layer}, chain...)
+		current = layer.extends
+	}
+	return chain, nil
+}
+
+// availableProfiles lists the profile names in alphabetical order for error messages.
+func availableProfiles(profiles map[string]profileLayer) string {
+	if len(profiles) == 0 {
+		return "none defined"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// decodeYAMLConfig decodes the top-level options of a YAML configuration file over cfg and returns its profiles.
+func decodeYAMLConfig(data []byte, cfg *Config) (map[string]profileLayer, error) {
+	var file struct {
+		Config   `yaml:",inline"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+	file.Config = *cfg
+	if err := decodeYAMLStrict(data, &file); err != nil {
+		return nil, err
+	}
+	*cfg = file.Config
+
+	profiles := make(map[string]profileLayer)
+	for name, node := range file.Profiles {
+		// Profiles are re-encoded so that they are decoded with the same strict rules as the top level
+		body, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		var options profileOptions
+		if err := decodeYAMLStrict(body, &options); err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		profiles[name] = profileLayer{
+			extends: options.Extends,
+			apply: func(cfg *Config) error {
+				options := profileOptions{Config: *cfg}
+				if err := decodeYAMLStrict(body, &options); err != nil {
+					return err
+				}
+				*cfg = options.Config
+				return nil
+			},
+		}
+	}
+	return profiles, nil
+}
+
+// decodeYAMLStrict decodes a YAML document into out, rejecting unknown keys. An empty document is not an error.
+func decodeYAMLStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeTOMLConfig decodes the top-level options of a TOML configuration file over cfg and returns its profiles.
+func decodeTOMLConfig(data []byte, cfg *Config) (map[string]profileLayer, error) {
+	var file struct {
+		Config
+		Profiles map[string]toml.Primitive `toml:"profiles"`
+	}
+	file.Config = *cfg
+	md, err := toml.Decode(string(data), &file)
+	if err != nil {
+		return nil, err
+	}
+	*cfg = file.Config
+
+	profiles := make(map[string]profileLayer)
+	for name, primitive := range file.Profiles {
+		var options profileOptions
+		if err := md.PrimitiveDecode(primitive, &options); err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		primitive := primitive
+		profiles[name] = profileLayer{
+			extends: options.Extends,
+			apply: func(cfg *Config) error {
+				options := profileOptions{Config: *cfg}
+				if err := md.PrimitiveDecode(primitive, &options); err != nil {
+					return err
+				}
+				*cfg = options.Config
+				return nil
+			},
+		}
+	}
+
+	// Every profile has been decoded once, so anything left over is an unknown key
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown option %q", undecoded[0].String())
+	}
+	return profiles, nil
+}
+
+// mergeFlags copies into dst every field of src whose flag was explicitly set on the command line.
+// Fields are matched to flags by their yaml key, which is always the flag name.
+func mergeFlags(dst *Config, src *Config, setFlags map[string]bool) {
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src).Elem()
+	configType := dstValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("yaml"), ",")[0]
+		if setFlags[name] {
+			dstValue.Field(i).Set(srcValue.Field(i))
+		}
+	}
+}
+
+// rebaseConfigPaths rewrites the paths and path globs read from the configuration file in configDir, which are
+// relative to configDir, so that they are relative to the working directory like those given as flags.
+func rebaseConfigPaths(cfg *Config, configDir string) {
+	toConfig, err := filepath.Rel(initialWorkingDir, configDir)
+	if err != nil || toConfig == "." {
+		return
+	}
+	fromConfig, _ := filepath.Rel(configDir, initialWorkingDir)
+
+	rebasePath := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		rel, _ := filepath.Rel(initialWorkingDir, filepath.Join(configDir, p))
+		return rel
+	}
+	for i, dir := range cfg.IncludeDir {
+		cfg.IncludeDir[i] = rebasePath(dir)
+	}
+	if cfg.Output != "-" {
+		cfg.Output = rebasePath(cfg.Output)
+	}
+	cfg.Template = rebasePath(cfg.Template)
+
+	cfg.ExcludeDir = rebaseGlobs(cfg.ExcludeDir, toConfig, fromConfig, true)
+	cfg.IncludePath = rebaseGlobs(cfg.IncludePath, toConfig, fromConfig, false)
+	cfg.ExcludePath = rebaseGlobs(cfg.ExcludePath, toConfig, fromConfig, false)
+	cfg.Priority = rebaseGlobs(cfg.Priority, toConfig, fromConfig, true)
+}
+
+// rebaseGlobs rewrites path globs relative to the configuration directory, which is toConfig from the working
+// directory, so that they match paths relative to the working directory. With matchNames, a glob without a slash
+// matches names at any depth and is kept as is. When the working directory is below the configuration directory,
+// its leading segments are stripped from each glob. A glob that cannot match anything below it is prefixed with
+// toConfig instead, so that it still only matches the paths it names, outside the working directory; dropping it
+// would turn an include-path list whose globs all point elsewhere into no filter at all.
+func rebaseGlobs(patterns []string, toConfig, fromConfig string, matchNames bool) []string {
+	var rebased []string
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		if matchNames && !strings.Contains(pattern, "/") {
+			rebased = append(rebased, pattern)
+			continue
+		}
+		pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "./"), "/")
+
+		outside := filepath.ToSlash(toConfig) + "/" + pattern
+		fromConfig := filepath.ToSlash(fromConfig)
+		if fromConfig == ".." || strings.HasPrefix(fromConfig, "../") {
+			rebased = append(rebased, outside)
+			continue
+		}
+		segments := strings.Split(pattern, "/")
+		matched := true
+		for _, dir := range strings.Split(fromConfig, "/") {
+			if segments[0] == "**" {
+				break
+			}
+			if len(segments) == 1 {
+				matched = false
+				break
+			}
+			if ok, err := path.Match(segments[0], dir); err != nil || !ok {
+				matched = false
+				break
+			}
+			segments = segments[1:]
+		}
+		if matched {
+			// The leading "./" keeps the glob anchored to the working directory
+			rebased = append(rebased, "./"+strings.Join(segments, "/"))
+		} else {
+			rebased = append(rebased, outside)
+		}
+	}
+	return rebased
+}
+
+// normalizeConfig fills in derived defaults and canonical forms once all sources have been merged.
+func normalizeConfig(cfg *Config) {
+	// If include-dir is not provided, process the current directory and all its subdirectories
+	if len(cfg.IncludeDir) == 0 {
+		cfg.IncludeDir = []string{"."}
+	}
+	cfg.IncludeExt = normalizeExtensions(cfg.IncludeExt)
+	cfg.ExcludeExt = normalizeExtensions(cfg.ExcludeExt)
+	cfg.TextExt = normalizeExtensions(cfg.TextExt)
+	cfg.BinaryExt = normalizeExtensions(cfg.BinaryExt)
+}
+
+// normalizeExtensions lowercases extensions and ensures each one starts with a dot.
+func normalizeExtensions(extensions []string) []string {
+	var normalized []string
+	for _, ext := range extensions {
+		trimmedExt := strings.TrimSpace(ext)
+		if trimmedExt == "" {
+			continue
+		}
+		if !strings.HasPrefix(trimmedExt, ".") {
+			trimmedExt = "." + trimmedExt
+		}
+		normalized = append(normalized, strings.ToLower(trimmedExt))
+	}
+	return normalized
+}
+
+// printConfig writes the effective configuration as YAML, in a form that can be saved as taco.yaml.
+func printConfig(cfg Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("error encoding configuration: %v", err)
+	}
+	if cfg.ConfigFile != "" {
+		fmt.Printf("# Loaded from %s\n", cfg.ConfigFile)
+	}
+	if cfg.Profile != "" {
+		fmt.Printf("# Profile: %s\n", cfg.Profile)
+	}
+	fmt.Print(string(data))
+	return nil
+}
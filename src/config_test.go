@@ -0,0 +1,249 @@
+// File: src/config_test.go
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFindConfigFile checks that the configuration file is discovered by walking up from the working directory.
+func TestFindConfigFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	os.MkdirAll(nested, 0755)
+	configPath := filepath.Join(root, "taco.toml")
+	os.WriteFile(configPath, []byte("output = \"bundle.txt\"\n"), 0644)
+
+	if found := findConfigFile(nested); found != configPath {
+		t.Errorf("Expected config file %s, got %q", configPath, found)
+	}
+}
+
+// TestLoadConfigFile checks that YAML and TOML files set the same options and that unknown keys are rejected.
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	initialWorkingDir = dir
+	yamlPath := filepath.Join(dir, "taco.yaml")
+	os.WriteFile(yamlPath, []byte("output: bundle.txt\ninclude-ext: [go, .MD]\nexclude-dir:\n  - vendor\nverbose: true\n"), 0644)
+	tomlPath := filepath.Join(dir, "taco.toml")
+	os.WriteFile(tomlPath, []byte("output = \"bundle.txt\"\ninclude-ext = [\"go\", \".MD\"]\nexclude-dir = [\"vendor\"]\nverbose = true\n"), 0644)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg := defaultConfig()
+		if err := loadConfigFile(path, &cfg, ""); err != nil {
+			t.Fatalf("Error loading %s: %v", path, err)
+		}
+		normalizeConfig(&cfg)
+		if cfg.Output != "bundle.txt" {
+			t.Errorf("%s: expected output 'bundle.txt', got %s", path, cfg.Output)
+		}
+		if len(cfg.IncludeExt) != 2 || cfg.IncludeExt[0] != ".go" || cfg.IncludeExt[1] != ".md" {
+			t.Errorf("%s: expected include extensions [.go .md], got %v", path, cfg.IncludeExt)
+		}
+		if len(cfg.ExcludeDir) != 1 || cfg.ExcludeDir[0] != "vendor" {
+			t.Errorf("%s: expected exclude directory 'vendor', got %v", path, cfg.ExcludeDir)
+		}
+		if !cfg.Verbose {
+			t.Errorf("%s: expected verbose to be true", path)
+		}
+	}
+
+	badPath := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(badPath, []byte("ouptut: typo.txt\n"), 0644)
+	cfg := defaultConfig()
+	if err := loadConfigFile(badPath, &cfg, ""); err == nil {
+		t.Error("Expected an error for an unknown option")
+	}
+}
+
+// TestParseArgumentsWithConfigFile checks that flags set on the command line take precedence over the configuration file.
+func TestParseArgumentsWithConfigFile(t *testing.T) {
+	initialWorkingDir = t.TempDir()
+	os.WriteFile(filepath.Join(initialWorkingDir, "taco.yaml"), []byte("output: from-config.txt\ninclude-ext: [.go]\nexclude-ext: [.log]\n"), 0644)
+
+	os.Args = []string{"cmd", "-include-ext", ".md"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err := parseArguments()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.Output != "from-config.txt" {
+		t.Errorf("Expected output from the config file, got %s", cfg.Output)
+	}
+	if len(cfg.IncludeExt) != 1 || cfg.IncludeExt[0] != ".md" {
+		t.Errorf("Expected include extensions from the flag [.md], got %v", cfg.IncludeExt)
+	}
+	if len(cfg.ExcludeExt) != 1 || cfg.ExcludeExt[0] != ".log" {
+		t.Errorf("Expected exclude extensions from the config file [.log], got %v", cfg.ExcludeExt)
+	}
+	if len(cfg.IncludeDir) != 1 || cfg.IncludeDir[0] != "." {
+		t.Errorf("Expected default include directory '.', got %v", cfg.IncludeDir)
+	}
+	if !strings.HasSuffix(cfg.ConfigFile, "taco.yaml") {
+		t.Errorf("Expected the discovered config file to be recorded, got %q", cfg.ConfigFile)
+	}
+}
+
+// TestParseArgumentsFromSubdirectory checks that the paths of a configuration file found above the working
+// directory are resolved against the file's directory, while those given as flags stay relative to the working directory.
+func TestParseArgumentsFromSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	initialWorkingDir = filepath.Join(root, "packages", "app")
+	os.MkdirAll(initialWorkingDir, 0755)
+	os.WriteFile(filepath.Join(root, "taco.yaml"), []byte(`include-dir: [docs, packages/app]
+output: out/bundle.txt
+template: templates/prompt.tmpl
+exclude-dir: [vendor, packages/*/dist, services/api/build]
+include-path: ["packages/app/**/*.go"]
+`), 0644)
+
+	os.Args = []string{"cmd", "-output", "flag.txt"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err := parseArguments()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedDirs := []string{filepath.Join("..", "..", "docs"), "."}
+	if strings.Join(cfg.IncludeDir, ",") != strings.Join(expectedDirs, ",") {
+		t.Errorf("Expected include directories %v, got %v", expectedDirs, cfg.IncludeDir)
+	}
+	if cfg.Output != "flag.txt" {
+		t.Errorf("Expected the output from the flag to stay relative to the working directory, got %s", cfg.Output)
+	}
+	if expected := filepath.Join("..", "..", "templates", "prompt.tmpl"); cfg.Template != expected {
+		t.Errorf("Expected template %s, got %s", expected, cfg.Template)
+	}
+	if strings.Join(cfg.ExcludeDir, ",") != "vendor,./dist,../../services/api/build" {
+		t.Errorf("Expected exclude directories [vendor ./dist ../../services/api/build], got %v", cfg.ExcludeDir)
+	}
+	if strings.Join(cfg.IncludePath, ",") != "./**/*.go" {
+		t.Errorf("Expected include paths [./**/*.go], got %v", cfg.IncludePath)
+	}
+
+	// Without the flag, the output is written next to the configuration file
+	os.Args = []string{"cmd"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err = parseArguments()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := filepath.Join("..", "..", "out", "bundle.txt"); cfg.Output != expected {
+		t.Errorf("Expected output %s, got %s", expected, cfg.Output)
+	}
+}
+
+// TestIncludePathOutsideSubdirectory checks that include path globs of a configuration file that cannot match
+// below the working directory still filter the files, rather than leaving no include filter at all.
+func TestIncludePathOutsideSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	initialWorkingDir = filepath.Join(root, "src")
+	os.MkdirAll(initialWorkingDir, 0755)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "taco.yaml"), []byte("include-path: [\"docs/**\"]\n"), 0644)
+	os.WriteFile(filepath.Join(root, "docs", "a.md"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(initialWorkingDir, "b.go"), []byte("b"), 0644)
+
+	os.Args = []string{"cmd"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err := parseArguments()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(cfg.IncludePath, ",") != "../docs/**" {
+		t.Errorf("Expected include paths [../docs/**], got %v", cfg.IncludePath)
+	}
+
+	opts, err := newOptions(cfg, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	candidates, err := collectFiles(opts)
+	if err != nil {
+		t.Fatalf("Error collecting files: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Errorf("Expected no files below src, got %v", candidates)
+	}
+
+	// The glob still matches the files it names when they are walked from the working directory
+	opts.directories = []string{".."}
+	candidates, err = collectFiles(opts)
+	if err != nil {
+		t.Fatalf("Error collecting files: %v", err)
+	}
+	if len(candidates) != 1 || filepath.ToSlash(candidates[0].relativePath) != "../docs/a.md" {
+		t.Errorf("Expected only ../docs/a.md, got %v", candidates)
+	}
+}
+
+// TestLoadConfigFileProfiles checks that profiles extend one another and that unknown profiles list the available ones.
+func TestLoadConfigFileProfiles(t *testing.T) {
+	dir := t.TempDir()
+	initialWorkingDir = dir
+	yamlPath := filepath.Join(dir, "taco.yaml")
+	os.WriteFile(yamlPath, []byte(`output: taco.txt
+exclude-dir: [vendor]
+profiles:
+  code:
+    include-dir: [src]
+    include-ext: [.go]
+  backend:
+    extends: code
+    exclude-file-pattern: ['_test\.go$']
+  docs:
+    include-ext: [.md]
+`), 0644)
+	tomlPath := filepath.Join(dir, "taco.toml")
+	os.WriteFile(tomlPath, []byte(`output = "taco.txt"
+exclude-dir = ["vendor"]
+
+[profiles.code]
+include-dir = ["src"]
+include-ext = [".go"]
+
+[profiles.backend]
+extends = "code"
+exclude-file-pattern = ['_test\.go$']
+
+[profiles.docs]
+include-ext = [".md"]
+`), 0644)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg := defaultConfig()
+		if err := loadConfigFile(path, &cfg, "backend"); err != nil {
+			t.Fatalf("Error loading %s: %v", path, err)
+		}
+		if len(cfg.IncludeDir) != 1 || cfg.IncludeDir[0] != "src" {
+			t.Errorf("%s: expected include directory from the extended profile [src], got %v", path, cfg.IncludeDir)
+		}
+		if len(cfg.IncludeExt) != 1 || cfg.IncludeExt[0] != ".go" {
+			t.Errorf("%s: expected include extensions from the extended profile [.go], got %v", path, cfg.IncludeExt)
+		}
+		if len(cfg.ExcludeFilePattern) != 1 || cfg.ExcludeFilePattern[0] != `_test\.go$` {
+			t.Errorf("%s: expected exclude pattern from the profile, got %v", path, cfg.ExcludeFilePattern)
+		}
+		if len(cfg.ExcludeDir) != 1 || cfg.ExcludeDir[0] != "vendor" {
+			t.Errorf("%s: expected exclude directory from the top level [vendor], got %v", path, cfg.ExcludeDir)
+		}
+
+		cfg = defaultConfig()
+		err := loadConfigFile(path, &cfg, "frontend")
+		if err == nil || !strings.Contains(err.Error(), "available profiles: backend, code, docs") {
+			t.Errorf("%s: expected an unknown profile error listing the available profiles, got %v", path, err)
+		}
+	}
+
+	cyclePath := filepath.Join(dir, "cycle.yaml")
+	os.WriteFile(cyclePath, []byte("profiles:\n  a:\n    extends: b\n  b:\n    extends: a\n"), 0644)
+	cfg := defaultConfig()
+	if err := loadConfigFile(cyclePath, &cfg, "a"); err == nil {
+		t.Error("Expected an error for profiles extending each other in a cycle")
+	}
+}
@@ -0,0 +1,188 @@
+// File: src/detect.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSampleSize = 8192 // Number of bytes sampled to tell text files from binary files
+	maxControlRatio   = 0.05 // Share of control characters above which a sample is binary
+	maxHighByteRatio  = 0.3  // Share of bytes above 0x7F above which a sample that is not UTF-8 is binary
+	minUTF16Ratio     = 0.9  // Share of code units with a NUL high byte for a sample without a BOM to be UTF-16
+)
+
+// defaultTextExts and defaultBinaryExts are the built-in extension overrides, consulted after -text-ext and
+// -binary-ext. Files with these extensions are classified without looking at their content.
+var (
+	defaultTextExts   = []string{".svg", ".ipynb"}
+	defaultBinaryExts = []string{
+		".png", ".jpg", ".jpeg", ".gif", ".bmp", ".ico", ".webp", ".pdf",
+		".zip", ".gz", ".tgz", ".bz2", ".xz", ".7z", ".rar", ".jar", ".war",
+		".exe", ".dll", ".so", ".dylib", ".o", ".a", ".class", ".pyc", ".wasm",
+		".mp3", ".mp4", ".wav", ".ogg", ".mov", ".ttf", ".otf", ".woff", ".woff2",
+		".sqlite", ".db",
+	}
+)
+
+// contentDetector tells text files from binary files, first by the extension overrides and then by sampling
+// the first bytes of each file.
+type contentDetector struct {
+	sampleSize int
+	textExts   map[string]struct{}
+	binaryExts map[string]struct{}
+}
+
+// newContentDetector builds a detector sampling sampleSize bytes. The extensions given by the user take
+// precedence over the built-in overrides.
+func newContentDetector(sampleSize int, textExts, binaryExts []string) *contentDetector {
+	detector := &contentDetector{
+		sampleSize: sampleSize,
+		textExts:   make(map[string]struct{}),
+		binaryExts: make(map[string]struct{}),
+	}
+	if detector.sampleSize <= 0 {
+		detector.sampleSize = defaultSampleSize
+	}
+	for _, ext := range defaultTextExts {
+		detector.textExts[ext] = struct{}{}
+	}
+	for _, ext := range defaultBinaryExts {
+		detector.binaryExts[ext] = struct{}{}
+	}
+	for _, ext := range textExts {
+		detector.textExts[ext] = struct{}{}
+		delete(detector.binaryExts, ext)
+	}
+	for _, ext := range binaryExts {
+		detector.binaryExts[ext] = struct{}{}
+		delete(detector.textExts, ext)
+	}
+	return detector
+}
+
+// contentKind is the result of classifying a file.
+type contentKind struct {
+	binary   bool
+	encoding string // Encoding of a text file: utf-8, utf-16le, utf-16be or latin-1
+	reason   string // Why the file was classified as binary
+}
+
+// classifyByExtension classifies a file by the extension overrides, reporting whether one applies.
+func (detector *contentDetector) classifyByExtension(path string) (contentKind, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := detector.binaryExts[ext]; ok {
+		return contentKind{binary: true, reason: fmt.Sprintf("extension %s is classified as binary", ext)}, true
+	}
+	if _, ok := detector.textExts[ext]; ok {
+		return contentKind{encoding: "utf-8"}, true
+	}
+	return contentKind{}, false
+}
+
+// classify classifies the sampled first bytes of a file. truncated tells whether the file goes on after
+// the sample, in which case the sample may end in the middle of a character.
+func classify(sample []byte, truncated bool) contentKind {
+	if len(sample) == 0 {
+		return contentKind{encoding: "utf-8"}
+	}
+
+	// A byte order mark settles the encoding
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return contentKind{encoding: "utf-8"}
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return contentKind{encoding: "utf-16le"}
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return contentKind{encoding: "utf-16be"}
+	}
+
+	// NUL bytes only appear in text encoded as UTF-16, where they are the high byte of ASCII characters
+	if nuls := bytes.Count(sample, []byte{0}); nuls > 0 {
+		if encoding := detectUTF16(sample); encoding != "" {
+			return contentKind{encoding: encoding}
+		}
+		return contentKind{binary: true, reason: fmt.Sprintf("contains NUL bytes (%d of %d sampled bytes, %s)",
+			nuls, len(sample), formatRatio(nuls, len(sample)))}
+	}
+
+	controls, highBytes := 0, 0
+	for _, b := range sample {
+		switch {
+		case b >= 0x80:
+			highBytes++
+		case b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\v' && b != 0x1B, b == 0x7F:
+			controls++
+		}
+	}
+	if float64(controls) > maxControlRatio*float64(len(sample)) {
+		return contentKind{binary: true, reason: fmt.Sprintf("contains too many control characters (%d of %d sampled bytes, %s)",
+			controls, len(sample), formatRatio(controls, len(sample)))}
+	}
+
+	if validUTF8(sample, truncated) {
+		return contentKind{encoding: "utf-8"}
+	}
+	// Legacy 8-bit text is mostly ASCII, with a few accented letters above 0x7F
+	if float64(highBytes) > maxHighByteRatio*float64(len(sample)) {
+		return contentKind{binary: true, reason: fmt.Sprintf("is not valid UTF-8 and %s of the sampled bytes are above 0x7F",
+			formatRatio(highBytes, len(sample)))}
+	}
+	return contentKind{encoding: "latin-1"}
+}
+
+// detectUTF16 recognizes UTF-16 text without a byte order mark from the position of its NUL bytes, returning
+// its encoding or "" if the sample does not look like UTF-16.
+func detectUTF16(sample []byte) string {
+	units := len(sample) / 2
+	if units == 0 {
+		return ""
+	}
+	var littleEndian, bigEndian int
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i+1] == 0 && isPrintableASCII(sample[i]) {
+			littleEndian++
+		} else if sample[i] == 0 && isPrintableASCII(sample[i+1]) {
+			bigEndian++
+		}
+	}
+	switch {
+	case float64(littleEndian) >= minUTF16Ratio*float64(units):
+		return "utf-16le"
+	case float64(bigEndian) >= minUTF16Ratio*float64(units):
+		return "utf-16be"
+	}
+	return ""
+}
+
+// isPrintableASCII reports whether b is a printable ASCII character or common whitespace.
+func isPrintableASCII(b byte) bool {
+	return (b >= 0x20 && b < 0x7F) || b == '\t' || b == '\n' || b == '\r'
+}
+
+// validUTF8 reports whether the sample is valid UTF-8. When the file goes on after the sample, a character
+// cut at the end of the sample is allowed.
+func validUTF8(sample []byte, truncated bool) bool {
+	if utf8.Valid(sample) {
+		return true
+	}
+	if !truncated {
+		return false
+	}
+	for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(sample[i]) {
+			return !utf8.FullRune(sample[i:]) && utf8.Valid(sample[:i])
+		}
+	}
+	return false
+}
+
+// formatRatio formats count out of total as a percentage, e.g. "0.4%".
+func formatRatio(count, total int) string {
+	return fmt.Sprintf("%.1f%%", 100*float64(count)/float64(total))
+}
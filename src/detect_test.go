@@ -0,0 +1,92 @@
+// File: src/detect_test.go
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// TestClassify checks the text and binary classification of sampled bytes, with the detected encoding.
+func TestClassify(t *testing.T) {
+	utf16LE := func(s string) []byte {
+		var b []byte
+		for _, unit := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(unit), byte(unit>>8))
+		}
+		return b
+	}
+	swap := func(b []byte) []byte {
+		swapped := make([]byte, len(b))
+		for i := 0; i+1 < len(b); i += 2 {
+			swapped[i], swapped[i+1] = b[i+1], b[i]
+		}
+		return swapped
+	}
+
+	tests := []struct {
+		name      string
+		sample    []byte
+		truncated bool
+		binary    bool
+		encoding  string
+	}{
+		{"empty", nil, false, false, "utf-8"},
+		{"ascii", []byte("package main\n"), false, false, "utf-8"},
+		{"utf-8", []byte("héllo wörld ✓\n"), false, false, "utf-8"},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFhello"), false, false, "utf-8"},
+		{"utf-8 cut by the sample", []byte("h\xC3\xA9llo \xE2\x9C"), true, false, "utf-8"},
+		{"utf-8 cut at the end of file", []byte("h\xC3\xA9llo, the last character is cut \xE2\x9C"), false, false, "latin-1"},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, utf16LE("int x;")...), false, false, "utf-16le"},
+		{"utf-16be bom", append([]byte{0xFE, 0xFF}, swap(utf16LE("int x;"))...), false, false, "utf-16be"},
+		{"utf-16le without bom", utf16LE("int main() {}\r\n"), false, false, "utf-16le"},
+		{"utf-16be without bom", swap(utf16LE("int main() {}\r\n")), false, false, "utf-16be"},
+		{"latin-1", []byte("caf\xE9 cr\xE8me br\xFBl\xE9e\n"), false, false, "latin-1"},
+		{"nul bytes", []byte{0x00, 0xFF, 0x00, 0xFF}, false, true, ""},
+		{"control characters", []byte("ab\x01\x02\x03\x04cd"), false, true, ""},
+		{"high bytes", []byte("\x89PNG\xE0\xF1\xA2\xB3\xC4\xD5\xE6\xF7\x88\x99"), false, true, ""},
+	}
+
+	for _, test := range tests {
+		kind := classify(test.sample, test.truncated)
+		if kind.binary != test.binary || kind.encoding != test.encoding {
+			t.Errorf("%s: expected binary %v and encoding %q, got binary %v and encoding %q (%s)",
+				test.name, test.binary, test.encoding, kind.binary, kind.encoding, kind.reason)
+		}
+		if kind.binary && kind.reason == "" {
+			t.Errorf("%s: expected a reason for the binary classification", test.name)
+		}
+	}
+}
+
+// TestClassifyByExtension checks that user overrides take precedence over the built-in extension table.
+func TestClassifyByExtension(t *testing.T) {
+	detector := newContentDetector(0, []string{".png"}, []string{".svg", ".lock"})
+	tests := []struct {
+		path       string
+		classified bool
+		binary     bool
+	}{
+		{"logo.png", true, false},
+		{"icon.svg", true, true},
+		{"yarn.lock", true, true},
+		{"notebook.IPYNB", true, false},
+		{"archive.zip", true, true},
+		{"main.go", false, false},
+		{"video.ts", false, false}, // Also used by MPEG transport streams, so it is left to the content check
+	}
+
+	for _, test := range tests {
+		kind, classified := detector.classifyByExtension(test.path)
+		if classified != test.classified || kind.binary != test.binary {
+			t.Errorf("%s: expected classified %v and binary %v, got %v and %v", test.path, test.classified, test.binary, classified, kind.binary)
+		}
+		if kind.binary && !strings.Contains(kind.reason, "extension") {
+			t.Errorf("%s: expected the reason to name the extension, got %q", test.path, kind.reason)
+		}
+	}
+	if detector.sampleSize != defaultSampleSize {
+		t.Errorf("Expected the default sample size, got %d", detector.sampleSize)
+	}
+}
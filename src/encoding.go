@@ -0,0 +1,86 @@
+// File: src/encoding.go
+
+package main
+
+import (
+	"bytes"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// eolModes lists the supported values of the -eol flag: lf converts CRLF line endings to LF, keep leaves them as is.
+var eolModes = []string{"lf", "keep"}
+
+// isEOLMode reports whether mode is a supported -eol mode.
+func isEOLMode(mode string) bool {
+	return slices.Contains(eolModes, mode)
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F to the characters Windows-1252 assigns them. Files detected as
+// Latin-1 are decoded with it, since legacy files declared as Latin-1 mostly use these bytes for punctuation
+// rather than for the control characters ISO-8859-1 assigns them. Unassigned bytes map to themselves.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeFile transcodes the content of a text file from the encoding it was read in to UTF-8, and applies
+// the -eol mode. A UTF-8 byte order mark is dropped.
+func decodeFile(file loadedFile, eol string) loadedFile {
+	if file.err != nil || file.binary {
+		return file
+	}
+	switch file.encoding {
+	case "utf-16le", "utf-16be":
+		file.content = decodeUTF16(file.content, file.encoding == "utf-16be")
+	case "latin-1":
+		file.content = decodeLatin1(file.content)
+	default:
+		file.content = bytes.TrimPrefix(file.content, []byte{0xEF, 0xBB, 0xBF})
+	}
+	if eol == "lf" {
+		file.content = bytes.ReplaceAll(file.content, []byte("\r\n"), []byte("\n"))
+	}
+	return file
+}
+
+// decodeUTF16 decodes UTF-16 text, dropping its byte order mark. A trailing odd byte becomes U+FFFD.
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	units := make([]uint16, 0, len(content)/2)
+	for i := 0; i+1 < len(content); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(content[i])<<8|uint16(content[i+1]))
+		} else {
+			units = append(units, uint16(content[i+1])<<8|uint16(content[i]))
+		}
+	}
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+
+	decoded := make([]byte, 0, len(content))
+	for _, r := range utf16.Decode(units) {
+		decoded = utf8.AppendRune(decoded, r)
+	}
+	if len(content)%2 == 1 {
+		decoded = utf8.AppendRune(decoded, utf8.RuneError)
+	}
+	return decoded
+}
+
+// decodeLatin1 decodes Latin-1 text, with the bytes 0x80 to 0x9F read as in Windows-1252.
+func decodeLatin1(content []byte) []byte {
+	decoded := make([]byte, 0, len(content)+len(content)/8)
+	for _, b := range content {
+		switch {
+		case b < 0x80:
+			decoded = append(decoded, b)
+		case b < 0xA0:
+			decoded = utf8.AppendRune(decoded, windows1252[b-0x80])
+		default:
+			decoded = utf8.AppendRune(decoded, rune(b))
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,60 @@
+// File: src/encoding_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDecodeFile checks that text files are transcoded to UTF-8 and that -eol=lf only converts CRLF line endings.
+func TestDecodeFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		eol      string
+		content  []byte
+		expected string
+	}{
+		{"utf-8", "utf-8", "keep", []byte("a\r\nb\n"), "a\r\nb\n"},
+		{"utf-8 bom", "utf-8", "keep", []byte("\xEF\xBB\xBFa"), "a"},
+		{"latin-1", "latin-1", "keep", []byte("caf\xE9 \x93quoted\x94 \x80"), "café “quoted” €"},
+		{"utf-16le", "utf-16le", "keep", []byte{0xFF, 0xFE, 'h', 0, 'i', 0, 0x3D, 0xD8, 0x00, 0xDE}, "hi😀"},
+		{"utf-16be", "utf-16be", "keep", []byte{0, 'h', 0, 0xE9, 0}, "hé�"},
+		{"lf", "utf-8", "lf", []byte("a\r\nb\rc\n"), "a\nb\rc\n"},
+		{"utf-16le lf", "utf-16le", "lf", []byte{'a', 0, '\r', 0, '\n', 0}, "a\n"},
+	}
+
+	for _, test := range tests {
+		file := decodeFile(loadedFile{content: test.content, encoding: test.encoding}, test.eol)
+		if string(file.content) != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, file.content)
+		}
+	}
+}
+
+// TestConcatenateFilesTranscoded checks that a Latin-1 file is written as UTF-8 with its encoding in the header.
+func TestConcatenateFilesTranscoded(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.WriteFile(filepath.Join(parentDir, "legacy.c"), []byte("/* caf\xE9 */\r\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "main.go"), []byte("package main\r\n"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		eol:            "lf",
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: legacy.c (transcoded from latin-1)\n\n/* café */\n\n// File: main.go\n\npackage main\n\n"
+	if string(data) != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, data)
+	}
+}
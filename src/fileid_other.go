@@ -0,0 +1,13 @@
+// File: src/fileid_other.go
+
+//go:build !unix
+
+package main
+
+import "os"
+
+// targetID identifies the file or directory behind info. Without device and inode numbers, the path with
+// all symbolic links resolved stands in for them.
+func targetID(path string, info os.FileInfo) fileID {
+	return resolvedID(path)
+}
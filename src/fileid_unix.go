@@ -0,0 +1,18 @@
+// File: src/fileid_unix.go
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// targetID identifies the file or directory behind info by its device and inode numbers.
+func targetID(path string, info os.FileInfo) fileID {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileID{device: uint64(stat.Dev), inode: uint64(stat.Ino)}
+	}
+	return resolvedID(path)
+}
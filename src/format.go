@@ -0,0 +1,420 @@
+// File: src/format.go
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// outputFormats lists the supported values of the -format flag.
+var outputFormats = []string{"plain", "markdown", "xml", "json", "jsonl"}
+
+// fileEntry is a file ready to be written to the output.
+type fileEntry struct {
+	path     string // Path relative to the working directory
+	absPath  string
+	modTime  time.Time
+	content  []byte
+	part     string // Part number and count, e.g. "2/3", when the file is split across output chunks
+	encoding string // Original encoding, e.g. "latin-1", when the file was transcoded to UTF-8
+}
+
+// partSuffix returns the " (part 2/3)" suffix added to the header of a split file, or "" for a whole file.
+func (file fileEntry) partSuffix() string {
+	if file.part == "" {
+		return ""
+	}
+	return " (part " + file.part + ")"
+}
+
+// encodingSuffix returns the " (transcoded from latin-1)" suffix added to the header of a transcoded file,
+// or "" for a file read as UTF-8.
+func (file fileEntry) encodingSuffix() string {
+	if file.encoding == "" {
+		return ""
+	}
+	return " (transcoded from " + file.encoding + ")"
+}
+
+// outputFormatter renders the concatenated output. writeHeader and writeFooter are called once for each output,
+// around one writeFile call per included file. When a directory tree is requested, writeTree is called once
+// right after writeHeader.
+type outputFormatter interface {
+	writeHeader(w io.Writer) error
+	writeTree(w io.Writer, tree string) error
+	writeFile(w io.Writer, file fileEntry) error
+	writeFooter(w io.Writer) error
+}
+
+// newFormatter returns the formatter for the given -format value. An empty format selects the plain format.
+// A template file, when given, takes precedence over the format.
+func newFormatter(format, templateFile string) (outputFormatter, error) {
+	if templateFile != "" {
+		return newTemplateFormatter(templateFile)
+	}
+	switch format {
+	case "", "plain":
+		return plainFormatter{}, nil
+	case "markdown", "md":
+		return markdownFormatter{}, nil
+	case "xml":
+		return xmlFormatter{}, nil
+	case "json":
+		return &jsonFormatter{}, nil
+	case "jsonl":
+		return jsonlFormatter{}, nil
+	}
+	return nil, fmt.Errorf("Invalid format %q: expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// isDocumentFormat reports whether the format wraps every file in a single document, which cannot be appended to.
+func isDocumentFormat(format string) bool {
+	return format == "xml" || format == "json"
+}
+
+// plainFormatter writes each file after a "// File: path" header line.
+type plainFormatter struct{}
+
+func (plainFormatter) writeHeader(w io.Writer) error { return nil }
+
+func (plainFormatter) writeTree(w io.Writer, tree string) error {
+	_, err := fmt.Fprintf(w, "// Directory Tree\n\n%s\n", tree)
+	return err
+}
+
+func (plainFormatter) writeFile(w io.Writer, file fileEntry) error {
+	// Write the file path to the output file
+	if _, err := fmt.Fprintf(w, "// File: %s%s%s\n\n", file.path, file.partSuffix(), file.encodingSuffix()); err != nil {
+		return fmt.Errorf("error writing file path to output file: %v", err)
+	}
+
+	// Copy the file content to the output file
+	if _, err := w.Write(file.content); err != nil {
+		return fmt.Errorf("error copying content from %s: %v", file.path, err)
+	}
+
+	// Write one newline to separate files
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("error writing separator to output file: %v", err)
+	}
+	return nil
+}
+
+func (plainFormatter) writeFooter(w io.Writer) error { return nil }
+
+// markdownFormatter writes each file under a "## path" heading, wrapped in a fenced code block tagged with its language.
+type markdownFormatter struct{}
+
+func (markdownFormatter) writeHeader(w io.Writer) error { return nil }
+
+func (markdownFormatter) writeTree(w io.Writer, tree string) error {
+	_, err := fmt.Fprintf(w, "## Directory Tree\n\n```\n%s```\n\n", tree)
+	return err
+}
+
+func (markdownFormatter) writeFile(w io.Writer, file fileEntry) error {
+	// The fence must be longer than any backtick run in the content so embedded fences do not close it
+	fence := strings.Repeat("`", max(3, longestRun(file.content, '`')+1))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s%s%s\n\n%s%s\n", filepath.ToSlash(file.path), file.partSuffix(), file.encodingSuffix(), fence, languageForFile(file.path))
+	sb.Write(file.content)
+	if len(file.content) > 0 && !bytes.HasSuffix(file.content, []byte("\n")) {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fence + "\n\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error writing %s to output file: %v", file.path, err)
+	}
+	return nil
+}
+
+func (markdownFormatter) writeFooter(w io.Writer) error { return nil }
+
+// xmlFormatter writes a well-formed XML document with one <document> element per file.
+// File content is wrapped in CDATA sections, so it is kept verbatim apart from characters XML cannot represent.
+type xmlFormatter struct{}
+
+func (xmlFormatter) writeHeader(w io.Writer) error {
+	_, err := io.WriteString(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<documents>\n")
+	return err
+}
+
+func (xmlFormatter) writeTree(w io.Writer, tree string) error {
+	var sb strings.Builder
+	sb.WriteString("<tree>")
+	writeCDATA(&sb, sanitizeXML(tree))
+	sb.WriteString("</tree>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (xmlFormatter) writeFile(w io.Writer, file fileEntry) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<document path=\"%s\" size=\"%d\"", escapeXML(filepath.ToSlash(file.path)), len(file.content))
+	if language := languageForFile(file.path); language != "" {
+		fmt.Fprintf(&sb, " language=\"%s\"", escapeXML(language))
+	}
+	if file.part != "" {
+		fmt.Fprintf(&sb, " part=\"%s\"", file.part)
+	}
+	if file.encoding != "" {
+		fmt.Fprintf(&sb, " original-encoding=\"%s\"", file.encoding)
+	}
+	sb.WriteString(">\n<content>")
+	writeCDATA(&sb, sanitizeXML(string(file.content)))
+	sb.WriteString("</content>\n</document>\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error writing %s to output file: %v", file.path, err)
+	}
+	return nil
+}
+
+func (xmlFormatter) writeFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "</documents>\n")
+	return err
+}
+
+// escapeXML escapes a string for use in an XML attribute value.
+func escapeXML(value string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(sanitizeXML(value)))
+	return sb.String()
+}
+
+// writeCDATA writes text as CDATA sections. A "]]>" sequence would end the section early,
+// so the section is closed between "]]" and ">" and a new one is opened.
+func writeCDATA(sb *strings.Builder, text string) {
+	if text == "" {
+		return
+	}
+	sb.WriteString("<![CDATA[")
+	sb.WriteString(strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>"))
+	sb.WriteString("]]>")
+}
+
+// sanitizeXML replaces invalid UTF-8 and characters that are not allowed in XML 1.0 with U+FFFD.
+func sanitizeXML(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r == utf8.RuneError, r < 0x20, r >= 0xD800 && r <= 0xDFFF, r == 0xFFFE, r == 0xFFFF:
+			return '\uFFFD'
+		}
+		return r
+	}, text)
+}
+
+// jsonFile is the JSON representation of a file, shared by the json and jsonl formats.
+type jsonFile struct {
+	Path     string `json:"path"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+	Language string `json:"language,omitempty"`
+	Part     string `json:"part,omitempty"`
+	Encoding string `json:"original_encoding,omitempty"`
+	Content  string `json:"content"`
+}
+
+// marshalJSONFile encodes a file as a single-line JSON object, without a trailing newline.
+func marshalJSONFile(file fileEntry) ([]byte, error) {
+	sum := sha256.Sum256(file.content)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(jsonFile{
+		Path:     filepath.ToSlash(file.path),
+		Size:     len(file.content),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Language: languageForFile(file.path),
+		Part:     file.part,
+		Encoding: file.encoding,
+		Content:  string(file.content),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding %s as JSON: %v", file.path, err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// marshalJSONString encodes a string as a JSON string literal without escaping HTML characters.
+func marshalJSONString(value string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("error encoding JSON string: %v", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonFormatter writes a single JSON document holding an array with one object per file,
+// preceded by a "tree" string when a directory tree is requested.
+type jsonFormatter struct {
+	count int // Number of files written so far, to place the separators
+}
+
+func (f *jsonFormatter) writeHeader(w io.Writer) error {
+	_, err := io.WriteString(w, "{")
+	return err
+}
+
+func (f *jsonFormatter) writeTree(w io.Writer, tree string) error {
+	data, err := marshalJSONString(tree)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\"tree\": "+string(data)+",\n")
+	return err
+}
+
+func (f *jsonFormatter) writeFile(w io.Writer, file fileEntry) error {
+	data, err := marshalJSONFile(file)
+	if err != nil {
+		return err
+	}
+	// The files array is opened with the first file, after the tree if there is one
+	separator := ",\n  "
+	if f.count == 0 {
+		separator = "\"files\": [\n  "
+	}
+	if _, err := io.WriteString(w, separator+string(data)); err != nil {
+		return fmt.Errorf("error writing %s to output file: %v", file.path, err)
+	}
+	f.count++
+	return nil
+}
+
+func (f *jsonFormatter) writeFooter(w io.Writer) error {
+	footer := "\n]}\n"
+	if f.count == 0 {
+		footer = "\"files\": [\n]}\n"
+	}
+	_, err := io.WriteString(w, footer)
+	return err
+}
+
+// jsonlFormatter writes one JSON object per line, so the output can be streamed and appended to.
+type jsonlFormatter struct{}
+
+func (jsonlFormatter) writeHeader(w io.Writer) error { return nil }
+
+func (jsonlFormatter) writeTree(w io.Writer, tree string) error {
+	data, err := marshalJSONString(tree)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "{\"tree\": "+string(data)+"}\n")
+	return err
+}
+
+func (jsonlFormatter) writeFile(w io.Writer, file fileEntry) error {
+	data, err := marshalJSONFile(file)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing %s to output file: %v", file.path, err)
+	}
+	return nil
+}
+
+func (jsonlFormatter) writeFooter(w io.Writer) error { return nil }
+
+// longestRun returns the length of the longest run of c in data.
+func longestRun(data []byte, c byte) int {
+	longest, current := 0, 0
+	for _, b := range data {
+		if b == c {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return longest
+}
+
+// languagesByExtension maps file extensions to the language names used to tag code blocks.
+var languagesByExtension = map[string]string{
+	".bash":       "bash",
+	".c":          "c",
+	".cc":         "cpp",
+	".clj":        "clojure",
+	".cpp":        "cpp",
+	".cs":         "csharp",
+	".css":        "css",
+	".dart":       "dart",
+	".dockerfile": "dockerfile",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".go":         "go",
+	".gradle":     "groovy",
+	".graphql":    "graphql",
+	".h":          "c",
+	".hpp":        "cpp",
+	".hs":         "haskell",
+	".html":       "html",
+	".ini":        "ini",
+	".java":       "java",
+	".js":         "javascript",
+	".json":       "json",
+	".jsx":        "jsx",
+	".kt":         "kotlin",
+	".lua":        "lua",
+	".md":         "markdown",
+	".mjs":        "javascript",
+	".php":        "php",
+	".pl":         "perl",
+	".proto":      "protobuf",
+	".ps1":        "powershell",
+	".py":         "python",
+	".r":          "r",
+	".rb":         "ruby",
+	".rs":         "rust",
+	".scala":      "scala",
+	".scss":       "scss",
+	".sh":         "bash",
+	".sql":        "sql",
+	".svelte":     "svelte",
+	".swift":      "swift",
+	".tf":         "hcl",
+	".toml":       "toml",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".vue":        "vue",
+	".xml":        "xml",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".zsh":        "bash",
+}
+
+// languagesByFileName maps well-known file names without a meaningful extension to their language.
+var languagesByFileName = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"CMakeLists.txt": "cmake",
+}
+
+// languageForFile infers the language of a file from its name or extension. It returns "" when unknown.
+func languageForFile(path string) string {
+	name := filepath.Base(path)
+	if language, ok := languagesByFileName[name]; ok {
+		return language
+	}
+	return languagesByExtension[strings.ToLower(filepath.Ext(name))]
+}
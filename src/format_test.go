@@ -0,0 +1,137 @@
+// File: src/format_test.go
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// TestMarkdownFormatter checks that files are fenced with a language tag and a fence longer than any embedded one.
+func TestMarkdownFormatter(t *testing.T) {
+	tests := []struct {
+		path     string
+		content  string
+		expected string
+	}{
+		{"src/main.go", "package main\n", "## src/main.go\n\n```go\npackage main\n```\n\n"},
+		{"notes.txt", "no newline", "## notes.txt\n\n```\nno newline\n```\n\n"},
+		{"README.md", "```go\ncode\n```\n", "## README.md\n\n````markdown\n```go\ncode\n```\n````\n\n"},
+		{"Makefile", "all:\n", "## Makefile\n\n```makefile\nall:\n```\n\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := (markdownFormatter{}).writeFile(&buf, fileEntry{path: test.path, content: []byte(test.content)}); err != nil {
+			t.Fatalf("Error formatting %s: %v", test.path, err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Expected markdown for %s:\n%q\nGot:\n%q", test.path, test.expected, buf.String())
+		}
+	}
+}
+
+// TestNewFormatter checks that unknown formats are rejected.
+func TestNewFormatter(t *testing.T) {
+	for _, format := range outputFormats {
+		if _, err := newFormatter(format, ""); err != nil {
+			t.Errorf("Expected format %q to be supported: %v", format, err)
+		}
+	}
+	if _, err := newFormatter("docx", ""); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestXMLFormatter checks that the XML output is well formed and preserves content containing markup and "]]>".
+func TestXMLFormatter(t *testing.T) {
+	content := "if a < b && c[d[0]]> 0 {\n\treturn \"<![CDATA[x]]>\"\n}\n"
+
+	var buf bytes.Buffer
+	formatter := xmlFormatter{}
+	formatter.writeHeader(&buf)
+	formatter.writeFile(&buf, fileEntry{path: "src/main.go", content: []byte(content)})
+	formatter.writeFile(&buf, fileEntry{path: `a"b&c.txt`, content: []byte("control\x01char")})
+	formatter.writeFooter(&buf)
+
+	var doc struct {
+		Documents []struct {
+			Path     string `xml:"path,attr"`
+			Size     int    `xml:"size,attr"`
+			Language string `xml:"language,attr"`
+			Content  string `xml:"content"`
+		} `xml:"document"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected well-formed XML, got error %v:\n%s", err, buf.String())
+	}
+	if len(doc.Documents) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(doc.Documents))
+	}
+	first := doc.Documents[0]
+	if first.Path != "src/main.go" || first.Size != len(content) || first.Language != "go" {
+		t.Errorf("Unexpected attributes: path=%q size=%d language=%q", first.Path, first.Size, first.Language)
+	}
+	if first.Content != content {
+		t.Errorf("Expected content to round-trip:\n%q\nGot:\n%q", content, first.Content)
+	}
+	second := doc.Documents[1]
+	if second.Path != `a"b&c.txt` || second.Content != "control\uFFFDchar" {
+		t.Errorf("Unexpected escaping: path=%q content=%q", second.Path, second.Content)
+	}
+}
+
+// TestJSONFormatters checks that the json format produces a single valid document and jsonl one object per line.
+func TestJSONFormatters(t *testing.T) {
+	files := []fileEntry{
+		{path: "main.go", content: []byte("package main\n")},
+		{path: "docs/notes.txt", content: []byte("<b>\"quoted\"</b>")},
+	}
+
+	var buf bytes.Buffer
+	formatter, _ := newFormatter("json", "")
+	formatter.writeHeader(&buf)
+	for _, file := range files {
+		formatter.writeFile(&buf, file)
+	}
+	formatter.writeFooter(&buf)
+
+	var doc struct {
+		Files []jsonFile `json:"files"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got error %v:\n%s", err, buf.String())
+	}
+	if len(doc.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(doc.Files))
+	}
+	first := doc.Files[0]
+	if first.Path != "main.go" || first.Size != 13 || first.Language != "go" || first.Content != "package main\n" {
+		t.Errorf("Unexpected file entry: %+v", first)
+	}
+	if first.SHA256 != "df1d036cbbf3df46e2045071e082245ece204c7f53ecf0a4e022bff9bb228f47" {
+		t.Errorf("Expected the SHA-256 digest of the content, got %q", first.SHA256)
+	}
+	if doc.Files[1].Content != "<b>\"quoted\"</b>" {
+		t.Errorf("Expected content to round-trip, got %q", doc.Files[1].Content)
+	}
+
+	buf.Reset()
+	formatter, _ = newFormatter("jsonl", "")
+	for _, file := range files {
+		formatter.writeFile(&buf, file)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var file jsonFile
+		if err := json.Unmarshal([]byte(line), &file); err != nil {
+			t.Errorf("Expected each line to be a JSON object, got error %v: %s", err, line)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// File: src/glob.go
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pathGlob is a glob pattern matched against slash-separated paths relative to the working directory.
+type pathGlob struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// compilePathGlobs compiles glob patterns, supporting "*", "?", "[...]" and "**" for any number of directories.
+// A pattern always matches the whole relative path, except that with matchNames a pattern without a slash
+// matches the name of a file at any depth, like in .gitignore files.
+func compilePathGlobs(patterns []string, matchNames bool) ([]pathGlob, error) {
+	var globs []pathGlob
+	for _, pattern := range patterns {
+		expr := globToRegexp(strings.TrimPrefix(strings.TrimPrefix(pattern, "./"), "/"))
+		if !matchNames || strings.Contains(pattern, "/") {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", pattern, err)
+		}
+		globs = append(globs, pathGlob{pattern: pattern, regex: re})
+	}
+	return globs, nil
+}
+
+// mayMatchBelow reports whether the glob could match a path inside the directory at relativeDir,
+// comparing the directory one segment at a time with the leading segments of the pattern.
+func (glob pathGlob) mayMatchBelow(relativeDir string) bool {
+	patternSegments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(glob.pattern, "./"), "/"), "/")
+	for i, segment := range strings.Split(filepath.ToSlash(relativeDir), "/") {
+		if i >= len(patternSegments)-1 {
+			// The last pattern segment can only match a file, not a directory above one
+			return patternSegments[len(patternSegments)-1] == "**"
+		}
+		if patternSegments[i] == "**" {
+			return true
+		}
+		if matched, err := path.Match(patternSegments[i], segment); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// namesHidden reports whether a segment of the glob starting with a dot matches name, so that the glob names
+// the hidden file or directory explicitly rather than through a wildcard.
+func (glob pathGlob) namesHidden(name string) bool {
+	for _, segment := range strings.Split(strings.TrimPrefix(glob.pattern, "./"), "/") {
+		if !strings.HasPrefix(segment, ".") {
+			continue
+		}
+		if matched, err := path.Match(segment, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobs returns the index of the first glob matching the relative path, or -1 if none does.
+func matchGlobs(relativePath string, globs []pathGlob) int {
+	slashPath := filepath.ToSlash(relativePath)
+	for i, glob := range globs {
+		if glob.regex.MatchString(slashPath) {
+			return i
+		}
+	}
+	return -1
+}
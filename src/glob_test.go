@@ -0,0 +1,98 @@
+// File: src/glob_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMatchGlobs checks doublestar globs against relative paths, with and without name matching.
+func TestMatchGlobs(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		matchNames bool
+		path       string
+		expected   bool
+	}{
+		{"**/testdata/**", false, "testdata/input.txt", true},
+		{"**/testdata/**", false, "pkg/parser/testdata/a/b.json", true},
+		{"**/testdata/**", false, "pkg/testdata.go", false},
+		{"internal/**/*_test.go", false, "internal/store/db_test.go", true},
+		{"internal/**/*_test.go", false, "internal/db_test.go", true},
+		{"internal/**/*_test.go", false, "cmd/internal/db_test.go", false},
+		{"*.go", false, "main.go", true},
+		{"*.go", false, "src/main.go", false},
+		{"*.go", true, "src/main.go", true},
+		{"./docs/*.md", false, "docs/intro.md", true},
+		{"src/[a-c]*.go", false, "src/budget.go", true},
+		{"src/[a-c]*.go", false, "src/main.go", false},
+	}
+
+	for _, test := range tests {
+		globs, err := compilePathGlobs([]string{test.pattern}, test.matchNames)
+		if err != nil {
+			t.Fatalf("Error compiling %q: %v", test.pattern, err)
+		}
+		if result := matchGlobs(filepath.FromSlash(test.path), globs) == 0; result != test.expected {
+			t.Errorf("Glob %q (match names %v) on %s: expected %v, got %v", test.pattern, test.matchNames, test.path, test.expected, result)
+		}
+	}
+}
+
+// TestConcatenateFilesWithPathGlobs checks that include and exclude path globs are applied to relative paths.
+func TestConcatenateFilesWithPathGlobs(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	for _, path := range []string{"internal/store/db_test.go", "internal/store/testdata/db_test.go", "internal/store/db.go", "cmd/app_test.go"} {
+		fullPath := filepath.Join(parentDir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(path+"\n"), 0644)
+	}
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	includePaths, _ := compilePathGlobs([]string{"internal/**/*_test.go"}, false)
+	excludePaths, _ := compilePathGlobs([]string{"**/testdata/**"}, false)
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		includePaths:   includePaths,
+		excludePaths:   excludePaths,
+		verbose:        true,
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: internal/store/db_test.go\n\ninternal/store/db_test.go\n\n"
+	if string(data) != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestMayMatchBelow checks whether a glob could match files inside a directory.
+func TestMayMatchBelow(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		dir      string
+		expected bool
+	}{
+		{".github/workflows/*.yml", ".github", true},
+		{".github/workflows/*.yml", ".github/workflows", true},
+		{".github/workflows/*.yml", ".github/workflows/old", false},
+		{".github/workflows/*.yml", ".vscode", false},
+		{"**/.env.example", ".config/app", true},
+		{".config/**", ".config/app/deep", true},
+		{"*.yml", ".github", false},
+	}
+
+	for _, test := range tests {
+		globs, _ := compilePathGlobs([]string{test.pattern}, false)
+		if result := globs[0].mayMatchBelow(filepath.FromSlash(test.dir)); result != test.expected {
+			t.Errorf("Glob %q below %s: expected %v, got %v", test.pattern, test.dir, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// File: src/ignore.go
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+const (
+	gitIgnoreFileName  = ".gitignore"
+	tacoIgnoreFileName = ".tacoignore"
+)
+
+// ignoreRule is a single pattern read from an ignore file.
+type ignoreRule struct {
+	regex    *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	override bool   // Set for .tacoignore rules, which take precedence over git's rules
+	base     string // Directory the pattern is relative to
+	source   string // Ignore file the pattern came from, for display
+	line     int    // Line number of the pattern in its source file
+}
+
+// ignoreMatcher holds every ignore rule in effect for a directory, ordered from lowest to highest precedence.
+// Rules from .tacoignore files are consulted before any git rule, so they can re-include files git ignores.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher builds the matcher in effect for the walk root dir. It loads the global excludes file,
+// .git/info/exclude and any ignore files in the ancestors of dir, so walking a subdirectory still honors
+// the rules declared above it. The ignore files inside dir itself are loaded by processDirectory.
+func newIgnoreMatcher(dir string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+
+	top := dir
+	repoRoot := findRepoRoot(dir)
+	if repoRoot != "" {
+		top = repoRoot
+		if excludesFile := globalExcludesFile(repoRoot); excludesFile != "" {
+			if err := m.addFile(excludesFile, repoRoot, false); err != nil {
+				return nil, err
+			}
+		}
+		if gitDir := findGitDir(repoRoot); gitDir != "" {
+			if err := m.addFile(filepath.Join(gitDir, "info", "exclude"), repoRoot, false); err != nil {
+				return nil, err
+			}
+		}
+	} else if isSubPath(initialWorkingDir, dir) {
+		top = initialWorkingDir
+	}
+
+	// Load the ignore files from the top directory down to, but not including, dir
+	rel, err := filepath.Rel(top, dir)
+	if err != nil || rel == "." {
+		return m, nil
+	}
+	current := top
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if err := m.addDirectoryFiles(current); err != nil {
+			return nil, err
+		}
+		current = filepath.Join(current, part)
+	}
+
+	return m, nil
+}
+
+// forDirectory returns the matcher in effect inside dir, extending m with the ignore files found there.
+// m itself is never modified, so sibling directories do not see each other's rules.
+// A nil matcher means ignore files are disabled and stays nil.
+func (m *ignoreMatcher) forDirectory(dir string) (*ignoreMatcher, error) {
+	if m == nil {
+		return nil, nil
+	}
+	child := &ignoreMatcher{rules: m.rules[:len(m.rules):len(m.rules)]}
+	if err := child.addDirectoryFiles(dir); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// addDirectoryFiles appends the rules of the .gitignore and .tacoignore files located in dir.
+func (m *ignoreMatcher) addDirectoryFiles(dir string) error {
+	if err := m.addFile(filepath.Join(dir, gitIgnoreFileName), dir, false); err != nil {
+		return err
+	}
+	return m.addFile(filepath.Join(dir, tacoIgnoreFileName), dir, true)
+}
+
+// addFile parses an ignore file and appends its rules. A missing file, or one whose parent is not
+// a directory, is not an error.
+func (m *ignoreMatcher) addFile(path, base string, override bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			return nil
+		}
+		return fmt.Errorf("error opening ignore file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	source, err := filepath.Rel(initialWorkingDir, path)
+	if err != nil || strings.HasPrefix(source, "..") {
+		source = path // Fallback to absolute path for files outside the working directory
+	}
+	source = filepath.ToSlash(source)
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		rule, ok := parseIgnoreLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		rule.override = override
+		rule.base = base
+		rule.source = source
+		rule.line = lineNumber
+		m.rules = append(m.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading ignore file %s: %v", path, err)
+	}
+	return nil
+}
+
+// match reports whether path is ignored, along with the rule that decided it.
+// As in git, the last matching rule wins and a negated rule re-includes the path.
+func (m *ignoreMatcher) match(path string, isDir bool) (*ignoreRule, bool) {
+	if m == nil {
+		return nil, false
+	}
+	if rule, matched := m.lastMatch(path, isDir, true); matched {
+		return rule, !rule.negate
+	}
+	if rule, matched := m.lastMatch(path, isDir, false); matched {
+		return rule, !rule.negate
+	}
+	return nil, false
+}
+
+// lastMatch returns the highest-precedence rule matching path among the .tacoignore rules (override)
+// or the git rules (!override).
+func (m *ignoreMatcher) lastMatch(path string, isDir bool, override bool) (*ignoreRule, bool) {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := &m.rules[i]
+		if rule.override != override || (rule.dirOnly && !isDir) {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rule.regex.MatchString(filepath.ToSlash(rel)) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// parseIgnoreLine converts one line of an ignore file into a rule. It returns false for blank lines and comments.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to the ignore file's directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	rule.regex = re
+	return rule, true
+}
+
+// globToRegexp translates a gitignore-style glob into a regular expression without anchors.
+// "*" and "?" never match a slash, while "**" spans any number of directories when it forms a whole path segment.
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				atSegmentStart := i == 0 || pattern[i-1] == '/'
+				j := i
+				for j < len(pattern) && pattern[j] == '*' {
+					j++
+				}
+				if atSegmentStart && j == len(pattern) {
+					// Trailing "/**" matches everything inside
+					sb.WriteString(".*")
+					i = j - 1
+					continue
+				}
+				if atSegmentStart && pattern[j] == '/' {
+					// Leading "**/" or middle "/**/" matches zero or more directories
+					sb.WriteString("(?:.*/)?")
+					i = j
+					continue
+				}
+				// Any other run of asterisks behaves like a single one
+				sb.WriteString("[^/]*")
+				i = j - 1
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				// A "]" right after the opening bracket is part of the set
+				if next := strings.IndexByte(pattern[i+2:], ']'); next >= 0 {
+					end = next + 1
+				} else {
+					end = -1
+				}
+			}
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			sb.WriteByte('[')
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				sb.WriteByte('^')
+				class = class[1:]
+			}
+			sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(class, `\`, `\\`), "[", `\[`))
+			sb.WriteByte(']')
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// findRepoRoot walks up from dir looking for a .git entry and returns the containing directory, or "" if none is found.
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findGitDir returns the git directory of the repository rooted at repoRoot, or "" if it cannot be found.
+// In worktrees and submodules .git is a file holding a "gitdir:" line that points to the git directory,
+// and a worktree shares the info directory of the main repository, named in its commondir file.
+func findGitDir(repoRoot string) string {
+	dotGit := filepath.Join(repoRoot, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(repoRoot, gitDir)
+	}
+	if commonDir, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		if dir := strings.TrimSpace(string(commonDir)); filepath.IsAbs(dir) {
+			gitDir = dir
+		} else {
+			gitDir = filepath.Join(gitDir, dir)
+		}
+	}
+	return gitDir
+}
+
+// globalExcludesFile returns the path of git's core.excludesFile, falling back to the XDG default location.
+func globalExcludesFile(repoRoot string) string {
+	cmd := exec.Command("git", "config", "--path", "--get", "core.excludesFile")
+	cmd.Dir = repoRoot
+	if out, err := cmd.Output(); err == nil {
+		if path := strings.TrimSpace(string(out)); path != "" {
+			return path
+		}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore")
+}
+
+// isSubPath reports whether path is parent itself or located inside it.
+func isSubPath(parent, path string) bool {
+	if parent == "" {
+		return false
+	}
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,223 @@
+// File: src/ignore_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseIgnoreLine checks that gitignore patterns are matched with git's semantics.
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		matches bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/debug.log", false, true},
+		{"*.log", "debug.txt", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/notes.txt", false, true},
+		{"doc/*.txt", "doc/server/arch.txt", false, false},
+		{"**/testdata", "a/b/testdata", true, true},
+		{"**/testdata", "testdata", true, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"logs/**", "logs/2024/app.log", false, true},
+		{"tmp/", "tmp", true, true},
+		{"tmp/", "tmp", false, false},
+		{"file?.go", "file1.go", false, true},
+		{"file[0-9].go", "file7.go", false, true},
+		{"file[!0-9].go", "file7.go", false, false},
+		{`\#notes`, "#notes", false, true},
+	}
+
+	for _, test := range tests {
+		rule, ok := parseIgnoreLine(test.pattern)
+		if !ok {
+			t.Fatalf("Expected pattern %q to be parsed", test.pattern)
+		}
+		matched := (!rule.dirOnly || test.isDir) && rule.regex.MatchString(test.path)
+		if matched != test.matches {
+			t.Errorf("Pattern %q against %q (dir=%v): expected match %v, got %v", test.pattern, test.path, test.isDir, test.matches, matched)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "!"} {
+		if _, ok := parseIgnoreLine(line); ok {
+			t.Errorf("Expected line %q to be skipped", line)
+		}
+	}
+}
+
+// TestIgnoreMatcherNegation checks that later rules and nested ignore files override earlier ones.
+func TestIgnoreMatcherNegation(t *testing.T) {
+	root := t.TempDir()
+	initialWorkingDir = root
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n!keep.log\n"), 0644)
+	os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("!debug.log\n"), 0644)
+
+	m, err := newIgnoreMatcher(root)
+	if err != nil {
+		t.Fatalf("Error creating ignore matcher: %v", err)
+	}
+	m, err = m.forDirectory(root)
+	if err != nil {
+		t.Fatalf("Error loading ignore file: %v", err)
+	}
+
+	rule, ignored := m.match(filepath.Join(root, "debug.log"), false)
+	if !ignored {
+		t.Fatal("Expected 'debug.log' to be ignored")
+	}
+	if rule.source != ".gitignore" || rule.line != 1 {
+		t.Errorf("Expected rule from .gitignore:1, got %s:%d", rule.source, rule.line)
+	}
+	if _, ignored := m.match(filepath.Join(root, "keep.log"), false); ignored {
+		t.Error("Expected 'keep.log' to be re-included by negation")
+	}
+
+	sub, err := m.forDirectory(filepath.Join(root, "sub"))
+	if err != nil {
+		t.Fatalf("Error loading nested ignore file: %v", err)
+	}
+	if _, ignored := sub.match(filepath.Join(root, "sub", "debug.log"), false); ignored {
+		t.Error("Expected 'sub/debug.log' to be re-included by the nested ignore file")
+	}
+	if _, ignored := m.match(filepath.Join(root, "sub", "debug.log"), false); !ignored {
+		t.Error("Expected the parent matcher to be unaffected by the nested ignore file")
+	}
+}
+
+// TestConcatenateFilesHonorsGitignore validates that ignored files and directories are left out of the output.
+func TestConcatenateFilesHonorsGitignore(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	os.MkdirAll(filepath.Join(parentDir, "src", "build"), 0755)
+	os.MkdirAll(filepath.Join(parentDir, ".git", "info"), 0755)
+	os.WriteFile(filepath.Join(parentDir, ".gitignore"), []byte("build/\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, ".git", "info", "exclude"), []byte("secret.txt\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "src", "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "src", "secret.txt"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "src", "build", "out.go"), []byte("generated"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	// Walk only the subdirectory so the root .gitignore must be picked up as an ancestor
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"src"},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		useIgnoreFiles: true,
+	}
+	err := concatenateFiles(opts)
+	if err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: src/main.go\n\nmain\n"
+	if string(data) != expected {
+		t.Errorf("Expected concatenated output:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestTacoignoreOverridesGitignore checks that .tacoignore rules are applied on top of git's rules
+// and that ignore files can be disabled entirely.
+func TestTacoignoreOverridesGitignore(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+
+	os.Mkdir(filepath.Join(parentDir, "sub"), 0755)
+	os.WriteFile(filepath.Join(parentDir, ".gitignore"), []byte("*.gen.go\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, ".tacoignore"), []byte("!api.gen.go\n*.lock\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "sub", ".gitignore"), []byte("!*.lock\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "sub", "api.gen.go"), []byte("api"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "sub", "db.gen.go"), []byte("db"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "sub", "deps.lock"), []byte("lock"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		useIgnoreFiles: true,
+	}
+
+	err := concatenateFiles(opts)
+	if err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: sub/api.gen.go\n\napi\n"
+	if string(data) != expected {
+		t.Errorf("Expected concatenated output:\n%s\nGot:\n%s", expected, data)
+	}
+
+	os.Remove(outputFile)
+	opts.useIgnoreFiles = false
+	err = concatenateFiles(opts)
+	if err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+	data, _ = os.ReadFile(outputFile)
+	expected = "// File: sub/api.gen.go\n\napi\n// File: sub/db.gen.go\n\ndb\n// File: sub/deps.lock\n\nlock\n"
+	if string(data) != expected {
+		t.Errorf("Expected concatenated output without ignore files:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestGitFileHonorsWorktreeExcludes checks that a .git file, as found in worktrees and submodules, is followed
+// to the git directory it names, and that a .git file pointing nowhere is not an error.
+func TestGitFileHonorsWorktreeExcludes(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	mainGitDir := filepath.Join(parentDir, "repo.git")
+	worktreeGitDir := filepath.Join(mainGitDir, "worktrees", "wt")
+	worktree := filepath.Join(parentDir, "wt")
+	os.MkdirAll(filepath.Join(mainGitDir, "info"), 0755)
+	os.MkdirAll(worktreeGitDir, 0755)
+	os.Mkdir(worktree, 0755)
+	os.WriteFile(filepath.Join(mainGitDir, "info", "exclude"), []byte("secret.txt\n"), 0644)
+	os.WriteFile(filepath.Join(worktreeGitDir, "commondir"), []byte("../..\n"), 0644)
+	os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: ../repo.git/worktrees/wt\n"), 0644)
+	os.WriteFile(filepath.Join(worktree, "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(worktree, "secret.txt"), []byte("secret"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"wt"},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		useIgnoreFiles: true,
+	}
+
+	err := concatenateFiles(opts)
+	if err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+	data, _ := os.ReadFile(outputFile)
+	expected := "// File: wt/main.go\n\nmain\n"
+	if string(data) != expected {
+		t.Errorf("Expected concatenated output:\n%s\nGot:\n%s", expected, data)
+	}
+
+	// A .git file pointing to a missing directory leaves only the ignore files of the tree
+	os.Remove(outputFile)
+	os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: /nonexistent\n"), 0644)
+	err = concatenateFiles(opts)
+	if err != nil {
+		t.Fatalf("Error concatenating files with a dangling .git file: %v", err)
+	}
+	data, _ = os.ReadFile(outputFile)
+	expected = "// File: wt/main.go\n\nmain\n// File: wt/secret.txt\n\nsecret\n"
+	if string(data) != expected {
+		t.Errorf("Expected concatenated output with a dangling .git file:\n%s\nGot:\n%s", expected, data)
+	}
+}
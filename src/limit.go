@@ -0,0 +1,131 @@
+// File: src/limit.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"unicode/utf8"
+)
+
+// oversizedModes lists the supported values of the -oversized flag: truncate keeps the head of a file over
+// -max-file-size or -max-file-lines, and its tail with -tail-lines, while skip leaves the file out.
+var oversizedModes = []string{"truncate", "skip"}
+
+// isOversizedMode reports whether mode is a supported -oversized mode.
+func isOversizedMode(mode string) bool {
+	return slices.Contains(oversizedModes, mode)
+}
+
+// limitsFile reports whether -max-file-size or -max-file-lines is set.
+func (opts *options) limitsFile() bool {
+	return opts.maxFileSize > 0 || opts.maxFileLines > 0
+}
+
+// loadCandidate reads a candidate file, transcodes it, redacts its secrets and applies the file size limits.
+// Secrets are redacted first, so that a truncated file never shows part of one. With -oversized=skip,
+// a file larger than -max-file-size on disk is not even read.
+func loadCandidate(candidate fileCandidate, detector *contentDetector, opts *options) loadedFile {
+	if opts.oversized == "skip" && opts.maxFileSize > 0 && candidate.size > opts.maxFileSize {
+		return loadedFile{fileCandidate: candidate, oversized: true,
+			limit: fmt.Sprintf("%s exceeds -max-file-size of %s", formatSize(candidate.size), formatSize(opts.maxFileSize))}
+	}
+	return applyFileLimits(redactFile(decodeFile(readCandidate(candidate, detector), opts.eol), opts.secretRules), opts)
+}
+
+// applyFileLimits skips or truncates a text file whose content exceeds -max-file-size or -max-file-lines.
+func applyFileLimits(file loadedFile, opts *options) loadedFile {
+	if file.err != nil || file.binary || !opts.limitsFile() {
+		return file
+	}
+	size, lines := int64(len(file.content)), countLines(file.content)
+	var exceeded string
+	switch {
+	case opts.maxFileSize > 0 && size > opts.maxFileSize:
+		exceeded = fmt.Sprintf("%s exceeds -max-file-size of %s", formatSize(size), formatSize(opts.maxFileSize))
+	case opts.maxFileLines > 0 && lines > opts.maxFileLines:
+		exceeded = fmt.Sprintf("%d lines exceed -max-file-lines of %d", lines, opts.maxFileLines)
+	default:
+		return file
+	}
+
+	if opts.oversized == "skip" {
+		file.oversized, file.limit, file.content = true, exceeded, nil
+		return file
+	}
+	content, kept := truncateContent(file.content, opts.maxFileSize, opts.maxFileLines, opts.tailLines)
+	file.content = content
+	file.limit = fmt.Sprintf("kept %d of %d lines (%s)", kept, lines, exceeded)
+	return file
+}
+
+// truncateContent keeps the first lines of content and, with tailLines, its last lines, so that the lines kept
+// fit in maxBytes and maxLines, where a zero limit is no limit. A "[truncated N lines]" marker replaces the lines
+// left out. A first line longer than maxBytes on its own is cut, so that some content is always kept.
+// It returns the truncated content and the number of lines kept whole.
+func truncateContent(content []byte, maxBytes int64, maxLines, tailLines int) ([]byte, int) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if maxLines <= 0 || maxLines > len(lines) {
+		maxLines = len(lines)
+	}
+	budget := maxBytes
+	fits := func(line []byte) bool {
+		if maxBytes <= 0 {
+			return true
+		}
+		if int64(len(line)) > budget {
+			return false
+		}
+		budget -= int64(len(line))
+		return true
+	}
+
+	// The tail is taken first, so that the head fills what is left
+	tailStart := len(lines)
+	for tailStart > 0 && len(lines)-tailStart < min(tailLines, maxLines-1) && fits(lines[tailStart-1]) {
+		tailStart--
+	}
+	head := 0
+	for head < tailStart && head+len(lines)-tailStart < maxLines && fits(lines[head]) {
+		head++
+	}
+
+	var truncated bytes.Buffer
+	for _, line := range lines[:head] {
+		truncated.Write(line)
+	}
+	if head == 0 && tailStart == len(lines) && maxBytes > 0 {
+		cut := int(maxBytes)
+		for cut > 0 && !utf8.RuneStart(lines[0][cut]) {
+			cut--
+		}
+		truncated.Write(lines[0][:cut])
+		truncated.WriteString("\n")
+	}
+	fmt.Fprintf(&truncated, "[truncated %d lines]\n", tailStart-head)
+	for _, line := range lines[tailStart:] {
+		truncated.Write(line)
+	}
+	return truncated.Bytes(), head + len(lines) - tailStart
+}
+
+// printLimitedFiles reports the files skipped or truncated by the file size limits.
+func printLimitedFiles(opts *options, report *fileReport) {
+	if len(report.skipped) > 0 {
+		opts.logf("Skipped %d files over the file size limits:\n", len(report.skipped))
+		for _, file := range report.skipped {
+			opts.logf("  %s: %s\n", filepath.ToSlash(file.relativePath), file.limit)
+		}
+	}
+	if len(report.truncated) > 0 {
+		opts.logf("Truncated %d files to the file size limits:\n", len(report.truncated))
+		for _, file := range report.truncated {
+			opts.logf("  %s: %s\n", filepath.ToSlash(file.relativePath), file.limit)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// File: src/limit_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTruncateContent checks that the head, and the tail when requested, fit the line and byte limits.
+func TestTruncateContent(t *testing.T) {
+	content := []byte("one\ntwo\nthree\nfour\nfive\n")
+	tests := []struct {
+		name      string
+		maxBytes  int64
+		maxLines  int
+		tailLines int
+		expected  string
+		kept      int
+	}{
+		{"lines", 0, 2, 0, "one\ntwo\n[truncated 3 lines]\n", 2},
+		{"lines with tail", 0, 3, 1, "one\ntwo\n[truncated 2 lines]\nfive\n", 3},
+		{"tail limited by lines", 0, 2, 5, "one\n[truncated 3 lines]\nfive\n", 2},
+		{"bytes", 9, 0, 0, "one\ntwo\n[truncated 3 lines]\n", 2},
+		{"bytes with tail", 10, 0, 1, "one\n[truncated 3 lines]\nfive\n", 2},
+		{"both", 100, 4, 0, "one\ntwo\nthree\nfour\n[truncated 1 lines]\n", 4},
+		{"first line too long", 2, 0, 0, "on\n[truncated 5 lines]\n", 0},
+	}
+
+	for _, test := range tests {
+		truncated, kept := truncateContent(content, test.maxBytes, test.maxLines, test.tailLines)
+		if string(truncated) != test.expected || kept != test.kept {
+			t.Errorf("%s: expected %q keeping %d lines, got %q keeping %d", test.name, test.expected, test.kept, truncated, kept)
+		}
+	}
+
+	// A first line is never cut in the middle of a character
+	truncated, _ := truncateContent([]byte("héllo\n"), 2, 0, 0)
+	if string(truncated) != "h\n[truncated 1 lines]\n" {
+		t.Errorf("Expected the cut to fall on a character boundary, got %q", truncated)
+	}
+}
+
+// TestConcatenateFilesWithFileLimits checks that oversized files are truncated or skipped and reported.
+func TestConcatenateFilesWithFileLimits(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.WriteFile(filepath.Join(parentDir, "dump.sql"), []byte("a\nb\nc\nd\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "main.go"), []byte("package main\n"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	tests := []struct {
+		oversized string
+		expected  string
+	}{
+		{"truncate", "// File: dump.sql\n\na\nb\n[truncated 1 lines]\nd\n\n// File: main.go\n\npackage main\n\n"},
+		{"skip", "// File: main.go\n\npackage main\n\n"},
+	}
+
+	for _, test := range tests {
+		opts := &options{
+			outputFilePath: outputFile,
+			directories:    []string{"."},
+			excludedPaths:  map[string]struct{}{outputFile: {}},
+			maxFileLines:   3,
+			tailLines:      1,
+			oversized:      test.oversized,
+		}
+		if err := concatenateFiles(opts); err != nil {
+			t.Fatalf("Error concatenating files: %v", err)
+		}
+		data, _ := os.ReadFile(outputFile)
+		if string(data) != test.expected {
+			t.Errorf("Mode %s: expected output:\n%s\nGot:\n%s", test.oversized, test.expected, data)
+		}
+	}
+
+	// Files larger than the size limit on disk are skipped without being read
+	file := loadCandidate(fileCandidate{path: filepath.Join(parentDir, "missing.sql"), size: 100}, newContentDetector(0, nil, nil),
+		&options{maxFileSize: 10, oversized: "skip"})
+	if !file.oversized || file.err != nil || file.limit != "100 B exceeds -max-file-size of 10 B" {
+		t.Errorf("Expected the file to be skipped by its size, got oversized %v (%s), error %v", file.oversized, file.limit, file.err)
+	}
+}
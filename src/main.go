@@ -6,116 +6,336 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 )
 
-const bufferSize = 512 // Number of bytes to read for content detection
-
 var initialWorkingDir string
 
-// parseArguments handles the command-line arguments and returns the output filename, directories to process,
-// included extensions, excluded extensions, included patterns, excluded patterns, excluded directories, verbosity flag, and an error if any.
-func parseArguments() (string, []string, []string, []string, []string, []string, []string, bool, error) {
+// parseArguments handles the command-line arguments and the project configuration file, and returns the
+// effective configuration. Options set on the command line take precedence over the configuration file.
+func parseArguments() (Config, error) {
 	// Define command-line flags
-	outputFileName := flag.String("output", "taco.txt", "The output file where the content will be concatenated")
-	includeExt := flag.String("include-ext", "", "Comma-separated list of file extensions to include (e.g., .go,.md)")
-	excludeExt := flag.String("exclude-ext", "", "Comma-separated list of file extensions to exclude (e.g., .test,.spec.js)")
-	excludeDir := flag.String("exclude-dir", "", "Comma-separated list of directories to exclude (e.g., vendor,tests)")
-	includeDir := flag.String("include-dir", "", "Comma-separated list of directories to include (e.g., src,docs,images). If not provided, the current directory and all its subdirectories will be processed.")
-	includeFilePattern := flag.String("include-file-pattern", "", "Comma-separated list of file patterns or regular expressions to include files")
-	excludeFilePattern := flag.String("exclude-file-pattern", "", "Comma-separated list of file patterns or regular expressions to exclude files")
-	verbose := flag.Bool("verbose", false, "Enable verbose output")
+	flags := defaultConfig()
+	flag.StringVar(&flags.Output, "output", flags.Output, "The output file where the content will be concatenated")
+	flag.StringVar(&flags.Mode, "mode", flags.Mode, "How to write the output file: overwrite replaces it, append adds to its existing content")
+	flag.StringVar(&flags.Format, "format", flags.Format, "Output format: "+strings.Join(outputFormats, ", "))
+	flag.StringVar(&flags.Template, "template", "", "Path to a Go text/template file defining the header, file and footer sections of the output. Overrides -format.")
+	flag.Var((*listValue)(&flags.IncludeExt), "include-ext", "Comma-separated list of file extensions to include (e.g., .go,.md)")
+	flag.Var((*listValue)(&flags.ExcludeExt), "exclude-ext", "Comma-separated list of file extensions to exclude (e.g., .test,.spec.js)")
+	flag.Var((*listValue)(&flags.TextExt), "text-ext", "Comma-separated list of file extensions always treated as text, without sampling their content (e.g., .svg,.ipynb)")
+	flag.Var((*listValue)(&flags.BinaryExt), "binary-ext", "Comma-separated list of file extensions always treated as binary and skipped (e.g., .pdf,.sqlite)")
+	flag.IntVar(&flags.SampleSize, "sample-size", flags.SampleSize, "Number of bytes sampled from the start of each file to tell text files from binary files")
+	flag.Var((*listValue)(&flags.ExcludeDir), "exclude-dir", "Comma-separated list of directory names or globs to exclude at any depth (e.g., vendor,build*,packages/*/dist)")
+	flag.BoolVar(&flags.Hidden, "hidden", false, "Include hidden files and directories (version control directories such as .git are always skipped)")
+	flag.StringVar(&flags.FollowSymlinks, "follow-symlinks", flags.FollowSymlinks, "Which symbolic links to follow: skip (none), files (links to files) or all (also linked directories)")
+	flag.BoolVar(&flags.NoDefaultExcludes, "no-default-excludes", false, "Do not skip well-known dependency and build directories such as node_modules, vendor and target")
+	flag.Var((*listValue)(&flags.IncludeDir), "include-dir", "Comma-separated list of directories to include (e.g., src,docs,images). If not provided, the current directory and all its subdirectories will be processed.")
+	flag.Var((*listValue)(&flags.IncludeFilePattern), "include-file-pattern", "Comma-separated list of file patterns or regular expressions to include files")
+	flag.Var((*listValue)(&flags.ExcludeFilePattern), "exclude-file-pattern", "Comma-separated list of file patterns or regular expressions to exclude files")
+	flag.StringVar(&flags.EOL, "eol", flags.EOL, "Line endings in the output: lf converts CRLF line endings to LF, keep leaves them as they are")
+	flag.Int64Var(&flags.MaxFileSize, "max-file-size", 0, "Maximum size of a file in bytes; larger files are truncated or skipped, see -oversized (default: no limit)")
+	flag.IntVar(&flags.MaxFileLines, "max-file-lines", 0, "Maximum number of lines of a file; longer files are truncated or skipped, see -oversized (default: no limit)")
+	flag.StringVar(&flags.Oversized, "oversized", flags.Oversized, "What to do with files over -max-file-size or -max-file-lines: truncate keeps their first lines, skip leaves them out")
+	flag.IntVar(&flags.TailLines, "tail-lines", 0, "Number of lines kept from the end of a truncated file, after the truncation marker")
+	flag.BoolVar(&flags.AllowSensitive, "allow-sensitive", false, "Include files likely to hold credentials, such as id_rsa, *.pem, credentials.json and .env, which are skipped by default")
+	flag.BoolVar(&flags.NoRedact, "no-redact", false, "Do not redact secrets such as API keys, tokens and private keys")
+	flag.BoolVar(&flags.FailOnSecrets, "fail-on-secrets", false, "Exit with an error and a report, without writing anything, when a secret is found")
+	flag.BoolVar(&flags.CountTokens, "count-tokens", false, "Print the number of tokens of each file and of the whole output, largest first")
+	flag.StringVar(&flags.Tokenizer, "tokenizer", flags.Tokenizer, "Tokenizer used to count tokens: "+strings.Join(tokenizerNames, ", "))
+	flag.IntVar(&flags.MaxTokens, "max-tokens", 0, "Maximum number of tokens of the output. The highest-ranked files that fit are included, the rest are dropped.")
+	flag.Int64Var(&flags.MaxBytes, "max-bytes", 0, "Maximum size of the output in bytes. The highest-ranked files that fit are included, the rest are dropped.")
+	flag.Var((*listValue)(&flags.Rank), "rank", "Comma-separated rules ranking files when a budget is set, applied in order: "+strings.Join(rankRules, ", "))
+	flag.Int64Var(&flags.SplitBytes, "split-bytes", 0, "Split the output into numbered chunks of at most this many bytes")
+	flag.IntVar(&flags.SplitTokens, "split-tokens", 0, "Split the output into numbered chunks of at most this many tokens")
+	flag.IntVar(&flags.SplitFiles, "split-files", 0, "Split the output into numbered chunks of at most this many files")
+	flag.BoolVar(&flags.Tree, "tree", false, "Write a directory tree of the included files before their content")
+	flag.BoolVar(&flags.TreeOnly, "tree-only", false, "Write only the directory tree of the included files")
+	flag.BoolVar(&flags.TreeSizes, "tree-sizes", false, "Show the size of each file and directory in the tree")
+	flag.BoolVar(&flags.TreeTokens, "tree-tokens", false, "Show the number of tokens of each file and directory in the tree")
+	flag.Var((*listValue)(&flags.IncludePath), "include-path", "Comma-separated globs of paths to include, relative to the current directory (e.g., internal/**/*_test.go)")
+	flag.Var((*listValue)(&flags.ExcludePath), "exclude-path", "Comma-separated globs of paths to exclude, relative to the current directory (e.g., **/testdata/**)")
+	flag.StringVar(&flags.Sort, "sort", "", "Order of the files across all include directories: "+strings.Join(sortOrders, ", ")+" (default: directory walk order)")
+	flag.Var((*listValue)(&flags.Priority), "priority", "Comma-separated globs of files that always come first, in the given order (e.g., README.md,go.mod,main.go)")
+	flag.IntVar(&flags.Jobs, "jobs", 0, "Number of files read in parallel (default: the number of CPUs)")
+	flag.BoolVar(&flags.Verbose, "verbose", false, "Enable verbose output")
+	flag.BoolVar(&flags.Stdout, "stdout", false, "Write the concatenated content to stdout instead of the output file (same as -output -)")
+	flag.BoolVar(&flags.NoIgnoreFiles, "no-ignore-files", false, "Disable .gitignore and .tacoignore handling")
+	flag.StringVar(&flags.ConfigFile, "config", "", "Path to a taco.yaml or taco.toml configuration file. If not provided, the nearest one found walking up from the current directory is used.")
+	flag.StringVar(&flags.Profile, "profile", "", "Name of a profile from the configuration file to apply on top of its top-level options")
+	flag.BoolVar(&flags.PrintConfig, "print-config", false, "Print the effective configuration and exit")
 	flag.Parse()
 
-	var directories []string
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
 
-	if *includeDir != "" {
-		// Parse the include-dir flag into directories
-		splitDir := strings.Split(*includeDir, ",")
-		for _, dir := range splitDir {
-			trimmedDir := strings.TrimSpace(dir)
-			if trimmedDir != "" {
-				directories = append(directories, trimmedDir)
-			}
+	// Load the configuration file, if any
+	cfg := defaultConfig()
+	cfg.ConfigFile = flags.ConfigFile
+	cfg.Profile = flags.Profile
+	if cfg.ConfigFile == "" {
+		cfg.ConfigFile = findConfigFile(initialWorkingDir)
+	}
+	if cfg.ConfigFile != "" {
+		if err := loadConfigFile(cfg.ConfigFile, &cfg, cfg.Profile); err != nil {
+			return Config{}, err
 		}
-	} else {
-		// If include-dir is not provided, process the current directory and all its subdirectories
-		directories = append(directories, ".")
+	} else if cfg.Profile != "" {
+		return Config{}, fmt.Errorf("profile %q requested but no taco.yaml or taco.toml configuration file was found", cfg.Profile)
 	}
 
-	// Parse the include extensions
-	var includeExtensions []string
-	if *includeExt != "" {
-		splitExt := strings.Split(*includeExt, ",")
-		for _, ext := range splitExt {
-			trimmedExt := strings.TrimSpace(ext)
-			if trimmedExt != "" {
-				// Ensure extensions start with a dot
-				if !strings.HasPrefix(trimmedExt, ".") {
-					trimmedExt = "." + trimmedExt
-				}
-				includeExtensions = append(includeExtensions, strings.ToLower(trimmedExt))
-			}
+	// Command-line flags override the configuration file
+	mergeFlags(&cfg, &flags, setFlags)
+	cfg.PrintConfig = flags.PrintConfig
+	normalizeConfig(&cfg)
+
+	return cfg, nil
+}
+
+// options holds the resolved settings used while walking the directories and writing the output file.
+type options struct {
+	outputFilePath  string
+	directories     []string
+	excludedPaths   map[string]struct{}
+	excludedDirs    []pathGlob
+	defaultExcludes []pathGlob
+	sensitiveFiles  []pathGlob
+	includeExts     []string
+	excludeExts     []string
+	textExts        []string
+	binaryExts      []string
+	sampleSize      int
+	eol             string
+	maxFileSize     int64
+	maxFileLines    int
+	oversized       string
+	tailLines       int
+	secretRules     []secretRule
+	failOnSecrets   bool
+	includePatterns []*regexp.Regexp
+	excludePatterns []*regexp.Regexp
+	useIgnoreFiles  bool
+	toStdout        bool
+	appendOutput    bool
+	format          string
+	templateFile    string
+	countTokens     bool
+	tokenizerName   string
+	maxTokens       int
+	maxBytes        int64
+	rankRules       []string
+	splitBytes      int64
+	splitTokens     int
+	splitFiles      int
+	tree            bool
+	treeOnly        bool
+	treeSizes       bool
+	treeTokens      bool
+	jobs            int
+	sortOrder       string
+	priority        []pathGlob
+	includePaths    []pathGlob
+	excludePaths    []pathGlob
+	hidden          bool
+	followSymlinks  string
+	verbose         bool
+}
+
+// logf prints a progress or verbose message. Messages go to stderr when the output itself is streamed to stdout,
+// so that they never end up mixed with the concatenated content.
+func (opts *options) logf(format string, args ...interface{}) {
+	var out io.Writer = os.Stdout
+	if opts.toStdout {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// newOptions resolves paths and compiles patterns from the effective configuration.
+func newOptions(cfg Config, scriptFilePath string) (*options, error) {
+	if cfg.Mode != "overwrite" && cfg.Mode != "append" {
+		return nil, fmt.Errorf("Invalid mode %q: expected overwrite or append", cfg.Mode)
+	}
+	if _, err := newFormatter(cfg.Format, cfg.Template); err != nil {
+		return nil, err
+	}
+	if !isSortOrder(cfg.Sort) {
+		return nil, fmt.Errorf("Invalid sort order %q: expected one of %s", cfg.Sort, strings.Join(sortOrders, ", "))
+	}
+	if cfg.SampleSize <= 0 {
+		return nil, fmt.Errorf("Invalid sample-size %d: expected a positive number of bytes", cfg.SampleSize)
+	}
+	for _, ext := range cfg.TextExt {
+		if slices.Contains(cfg.BinaryExt, ext) {
+			return nil, fmt.Errorf("Invalid text-ext %q: also listed in binary-ext", ext)
+		}
+	}
+	if !isEOLMode(cfg.EOL) {
+		return nil, fmt.Errorf("Invalid eol mode %q: expected one of %s", cfg.EOL, strings.Join(eolModes, ", "))
+	}
+	if !isOversizedMode(cfg.Oversized) {
+		return nil, fmt.Errorf("Invalid oversized mode %q: expected one of %s", cfg.Oversized, strings.Join(oversizedModes, ", "))
+	}
+	if cfg.MaxFileLines > 0 && cfg.TailLines >= cfg.MaxFileLines {
+		return nil, fmt.Errorf("Invalid tail-lines %d: must be less than max-file-lines (%d)", cfg.TailLines, cfg.MaxFileLines)
+	}
+	if cfg.NoRedact && cfg.FailOnSecrets {
+		return nil, fmt.Errorf("Invalid option -fail-on-secrets: secrets are not detected with -no-redact")
+	}
+	if !isSymlinkMode(cfg.FollowSymlinks) {
+		return nil, fmt.Errorf("Invalid follow-symlinks mode %q: expected one of %s", cfg.FollowSymlinks, strings.Join(symlinkModes, ", "))
+	}
+	for _, rule := range cfg.Rank {
+		if !isRankRule(rule) {
+			return nil, fmt.Errorf("Invalid rank rule %q: expected one of %s", rule, strings.Join(rankRules, ", "))
 		}
 	}
+	if cfg.Mode == "append" && cfg.Template == "" && isDocumentFormat(cfg.Format) {
+		return nil, fmt.Errorf("Invalid mode %q: the %s format produces a single document and cannot be appended to", cfg.Mode, cfg.Format)
+	}
 
-	// Parse the exclude extensions
-	var excludeExtensions []string
-	if *excludeExt != "" {
-		splitExt := strings.Split(*excludeExt, ",")
-		for _, ext := range splitExt {
-			trimmedExt := strings.TrimSpace(ext)
-			if trimmedExt != "" {
-				// Ensure extensions start with a dot
-				if !strings.HasPrefix(trimmedExt, ".") {
-					trimmedExt = "." + trimmedExt
-				}
-				excludeExtensions = append(excludeExtensions, strings.ToLower(trimmedExt))
-			}
+	// An output of "-" streams the concatenated content to stdout, like -stdout
+	toStdout := cfg.Stdout || cfg.Output == "-"
+
+	splitting := cfg.SplitBytes > 0 || cfg.SplitTokens > 0 || cfg.SplitFiles > 0
+	if splitting && toStdout {
+		return nil, fmt.Errorf("Invalid output %q: splitting the output into chunks requires an output file", cfg.Output)
+	}
+	if splitting && cfg.Mode == "append" {
+		return nil, fmt.Errorf("Invalid mode %q: chunks cannot be appended to", cfg.Mode)
+	}
+	if splitting && cfg.TreeOnly {
+		return nil, fmt.Errorf("Invalid option -tree-only: a directory tree cannot be split into chunks")
+	}
+
+	// Read as many files in parallel as there are CPUs available by default
+	jobs := cfg.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	// Get the absolute path of the output file
+	var outputFilePath string
+	if !toStdout {
+		var err error
+		outputFilePath, err = filepath.Abs(cfg.Output)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting absolute path of output file: %v", err)
 		}
 	}
 
-	// Parse the include file patterns
-	var includePatterns []string
-	if *includeFilePattern != "" {
-		splitPatterns := strings.Split(*includeFilePattern, ",")
-		for _, pattern := range splitPatterns {
-			trimmedPattern := strings.TrimSpace(pattern)
-			if trimmedPattern != "" {
-				includePatterns = append(includePatterns, trimmedPattern)
-			}
+	opts := &options{
+		outputFilePath: outputFilePath,
+		directories:    cfg.IncludeDir,
+		// Get the list of paths to exclude (script and output file)
+		excludedPaths:  getExcludedPaths(outputFilePath, scriptFilePath),
+		includeExts:    cfg.IncludeExt,
+		excludeExts:    cfg.ExcludeExt,
+		textExts:       cfg.TextExt,
+		binaryExts:     cfg.BinaryExt,
+		sampleSize:     cfg.SampleSize,
+		eol:            cfg.EOL,
+		maxFileSize:    cfg.MaxFileSize,
+		maxFileLines:   cfg.MaxFileLines,
+		oversized:      cfg.Oversized,
+		tailLines:      cfg.TailLines,
+		failOnSecrets:  cfg.FailOnSecrets,
+		useIgnoreFiles: !cfg.NoIgnoreFiles,
+		toStdout:       toStdout,
+		appendOutput:   cfg.Mode == "append",
+		format:         cfg.Format,
+		templateFile:   cfg.Template,
+		countTokens:    cfg.CountTokens,
+		tokenizerName:  cfg.Tokenizer,
+		maxTokens:      cfg.MaxTokens,
+		maxBytes:       cfg.MaxBytes,
+		rankRules:      cfg.Rank,
+		splitBytes:     cfg.SplitBytes,
+		splitTokens:    cfg.SplitTokens,
+		splitFiles:     cfg.SplitFiles,
+		tree:           cfg.Tree || cfg.TreeOnly,
+		treeOnly:       cfg.TreeOnly,
+		treeSizes:      cfg.TreeSizes,
+		treeTokens:     cfg.TreeTokens,
+		jobs:           jobs,
+		sortOrder:      cfg.Sort,
+		hidden:         cfg.Hidden,
+		followSymlinks: cfg.FollowSymlinks,
+		verbose:        cfg.Verbose,
+	}
+
+	// Compile the excluded directories. A name or glob without a slash matches directories at any depth,
+	// one with a slash matches the path relative to the current directory.
+	var excludedDirs []string
+	for _, dir := range cfg.ExcludeDir {
+		// Normalize directory paths to use forward slashes
+		excludedDirs = append(excludedDirs, strings.TrimSuffix(filepath.ToSlash(dir), "/"))
+	}
+	var err error
+	if opts.excludedDirs, err = compilePathGlobs(excludedDirs, true); err != nil {
+		return nil, fmt.Errorf("Invalid exclude-dir %v", err)
+	}
+	if !cfg.NoDefaultExcludes {
+		opts.defaultExcludes, _ = compilePathGlobs(defaultExcludedDirs, true)
+	}
+	if !cfg.AllowSensitive {
+		opts.sensitiveFiles, _ = compilePathGlobs(defaultSensitiveFiles, true)
+	}
+
+	// Compile the globs of the files written first
+	priority, err := compilePathGlobs(cfg.Priority, true)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid priority glob %v", err)
+	}
+	opts.priority = priority
+
+	// Compile the include and exclude path globs
+	if opts.includePaths, err = compilePathGlobs(cfg.IncludePath, false); err != nil {
+		return nil, fmt.Errorf("Invalid include-path %v", err)
+	}
+	if opts.excludePaths, err = compilePathGlobs(cfg.ExcludePath, false); err != nil {
+		return nil, fmt.Errorf("Invalid exclude-path %v", err)
+	}
+	if !cfg.NoRedact {
+		if opts.secretRules, err = compileSecretRules(cfg.SecretRules); err != nil {
+			return nil, fmt.Errorf("Invalid secret rule %v", err)
 		}
 	}
 
-	// Parse the exclude file patterns
-	var excludePatterns []string
-	if *excludeFilePattern != "" {
-		splitPatterns := strings.Split(*excludeFilePattern, ",")
-		for _, pattern := range splitPatterns {
-			trimmedPattern := strings.TrimSpace(pattern)
-			if trimmedPattern != "" {
-				excludePatterns = append(excludePatterns, trimmedPattern)
-			}
+	// Compile include patterns into regular expressions
+	for _, pattern := range cfg.IncludeFilePattern {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid include-file-pattern %q: %v", pattern, err)
 		}
+		opts.includePatterns = append(opts.includePatterns, re)
 	}
 
-	// Parse the exclude directories
-	var excludedDirectories []string
-	if *excludeDir != "" {
-		splitDir := strings.Split(*excludeDir, ",")
-		for _, dir := range splitDir {
-			trimmedDir := strings.TrimSpace(dir)
-			if trimmedDir != "" {
-				excludedDirectories = append(excludedDirectories, trimmedDir)
-			}
+	// Compile exclude patterns into regular expressions
+	for _, pattern := range cfg.ExcludeFilePattern {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid exclude-file-pattern %q: %v", pattern, err)
 		}
+		opts.excludePatterns = append(opts.excludePatterns, re)
 	}
 
-	return *outputFileName, directories, includeExtensions, excludeExtensions, includePatterns, excludePatterns, excludedDirectories, *verbose, nil
+	return opts, nil
+}
+
+// defaultExcludedDirs are the dependency, cache and build directories skipped at any depth unless
+// -no-default-excludes is set.
+var defaultExcludedDirs = []string{
+	"node_modules", "bower_components", "jspm_packages", "vendor",
+	"__pycache__", "venv", ".venv", ".tox", ".mypy_cache", ".pytest_cache",
+	"target", "build", "dist", ".gradle", ".next", ".nuxt", "coverage",
 }
 
 // getExcludedPaths returns a map containing full paths to exclude (the script itself and the output file).
@@ -127,129 +347,498 @@ func getExcludedPaths(outputFilePath, scriptFilePath string) map[string]struct{}
 }
 
 // concatenateFiles processes the directories and writes the content of each text file to the output file.
-func concatenateFiles(outputFilePath string, directories []string, excludedPaths map[string]struct{}, excludedDirs map[string]struct{}, includeExts, excludeExts []string, includePatterns, excludePatterns []*regexp.Regexp, verbose bool) error {
-	var anyFilesProcessed bool = false
-	var outputFile *os.File
+// When ignore files are enabled, files matched by .gitignore and .tacoignore rules are skipped.
+// When a token or byte budget is set, only the highest-ranked files that fit are written.
+func concatenateFiles(opts *options) error {
+	var completed bool = false
+
+	formatter, err := newFormatter(opts.format, opts.templateFile)
+	if err != nil {
+		return err
+	}
+
+	// Load the tokenizer once for the token budget, the chunk size, the tree and the token report
+	var tokenizer tokenizer
+	if opts.countTokens || opts.maxTokens > 0 || opts.splitTokens > 0 || opts.treeTokens {
+		tokenizer, err = newTokenizer(opts.tokenizerName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Count the tokens of everything written when a token report is requested
+	var counter *tokenCountingFormatter
+	if opts.countTokens {
+		counter = &tokenCountingFormatter{outputFormatter: formatter, tokenizer: tokenizer}
+		formatter = counter
+	}
+	out := &output{formatter: formatter}
 
 	defer func() {
-		if outputFile != nil {
-			outputFile.Close()
+		if out.file != nil && out.file != os.Stdout {
+			out.file.Close()
+			// Discard the temporary file of an unfinished overwrite so the previous output is left untouched
+			if !completed && !opts.appendOutput {
+				os.Remove(out.file.Name())
+			}
 		}
 	}()
 
-	for _, dir := range directories {
+	candidates, err := collectFiles(opts)
+	if err != nil {
+		return err
+	}
+	sortFiles(candidates, opts)
+
+	// Read the files in parallel; they are delivered in walk order
+	done := make(chan struct{})
+	defer close(done)
+	files := readFiles(candidates, opts, done)
+
+	// The budget, the tree and the chunks need every file before the first one is written
+	var anyFilesProcessed bool
+	report := &fileReport{}
+	loadAll := opts.maxTokens > 0 || opts.maxBytes > 0 || opts.tree || opts.splitting() || opts.failOnSecrets
+	if loadAll {
+		loaded := loadFiles(files, opts, report)
+
+		// Write nothing if any file holds a secret
+		if opts.failOnSecrets && len(report.redacted) > 0 {
+			printSecretReport(opts, report.redacted, false)
+			return fmt.Errorf("Found secrets in %d files, nothing was written (remove them, or run without -fail-on-secrets to redact them)", len(report.redacted))
+		}
+
+		// Keep only the files that fit the budget
+		if opts.maxTokens > 0 || opts.maxBytes > 0 {
+			var dropped []droppedFile
+			loaded, dropped, err = applyBudget(loaded, opts, tokenizer)
+			if err != nil {
+				return err
+			}
+			printDroppedFiles(opts, dropped)
+		}
+
+		// Build the directory tree from the files that will be written, so it never shows excluded files
+		if opts.tree && len(loaded) > 0 {
+			out.tree = buildTree(loaded, opts, tokenizer)
+		}
+		anyFilesProcessed = len(loaded) > 0
+
+		if opts.splitting() {
+			if err := concatenateChunks(loaded, opts, tokenizer, counter, out.tree); err != nil {
+				return err
+			}
+			printLimitedFiles(opts, report)
+			printSecretReport(opts, report.redacted, true)
+			return nil
+		}
+
+		// Only the tree is written in tree-only mode
+		if opts.treeOnly {
+			if anyFilesProcessed {
+				if err := out.open(opts); err != nil {
+					return err
+				}
+			}
+			loaded = nil
+		}
+
+		replay := make(chan loadedFile, len(loaded))
+		for _, file := range loaded {
+			replay <- file
+		}
+		close(replay)
+		files = replay
+	}
+
+	for file := range files {
+		if file.binary && opts.verbose {
+			opts.logf("Skipping file %s: not a text file (%s)\n", file.relativePath, file.reason)
+		}
+		if file.binary {
+			continue
+		}
+		// Loaded files were already noted
+		if !loadAll && report.note(file, opts) {
+			continue
+		}
+		anyFilesProcessed = true
+
+		// Open output file if not already opened
+		if out.file == nil {
+			if err := out.open(opts); err != nil {
+				return err
+			}
+		}
+
+		// Processing status in a single line
+		opts.logf("Processing %s ... ", file.relativePath)
+
+		// Write file content to the output file
+		err = file.err
+		if err == nil {
+			err = writeFileContent(out.file, out.formatter, file)
+		}
+		if err != nil {
+			opts.logf("Error\n")
+			opts.logf("Error processing file %s: %v\n", file.relativePath, err)
+		} else {
+			// Indicate completion on the same line
+			opts.logf("Done\n")
+		}
+	}
+
+	if out.file != nil {
+		if err := out.formatter.writeFooter(out.file); err != nil {
+			return fmt.Errorf("error writing output footer: %v", err)
+		}
+	}
+
+	// Replace the output file with the completed temporary file
+	if out.file != nil && out.file != os.Stdout && !opts.appendOutput {
+		if err := out.file.Close(); err != nil {
+			return fmt.Errorf("error closing output file: %v", err)
+		}
+		if err := os.Rename(out.file.Name(), opts.outputFilePath); err != nil {
+			return fmt.Errorf("error replacing output file: %v", err)
+		}
+	}
+	completed = true
+
+	if !anyFilesProcessed && opts.verbose {
+		opts.logf("No text files found in any of the directories.\n")
+	} else if anyFilesProcessed {
+		result := "Files concatenated"
+		if opts.treeOnly {
+			result = "Directory tree written"
+		}
+		if opts.toStdout {
+			opts.logf("%s successfully to stdout\n", result)
+		} else {
+			// Compute relative path of the output file for display
+			relativeOutputPath, err := filepath.Rel(initialWorkingDir, opts.outputFilePath)
+			if err != nil {
+				relativeOutputPath = opts.outputFilePath // Fallback to absolute path
+			}
+			opts.logf("%s successfully into %s\n", result, relativeOutputPath)
+		}
+	}
+
+	printLimitedFiles(opts, report)
+	printSecretReport(opts, report.redacted, true)
+	if counter != nil && anyFilesProcessed {
+		printTokenReport(opts, counter)
+	}
+
+	return nil
+}
+
+// concatenateChunks writes the files to numbered chunks instead of a single output file and reports the result.
+func concatenateChunks(files []loadedFile, opts *options, tokenizer tokenizer, counter *tokenCountingFormatter, tree string) error {
+	chunks, err := writeChunks(files, opts, tokenizer, counter, tree)
+	if err != nil {
+		return err
+	}
+
+	if chunks == 0 {
+		if opts.verbose {
+			opts.logf("No text files found in any of the directories.\n")
+		}
+		return nil
+	}
+
+	// Compute relative path of the index for display
+	relativeIndexPath, err := filepath.Rel(initialWorkingDir, chunkIndexPath(opts.outputFilePath))
+	if err != nil {
+		relativeIndexPath = chunkIndexPath(opts.outputFilePath) // Fallback to absolute path
+	}
+	opts.logf("Files concatenated successfully into %d chunks, listed in %s\n", chunks, relativeIndexPath)
+
+	if counter != nil {
+		printTokenReport(opts, counter)
+	}
+	return nil
+}
+
+// fileCandidate is a file found by the directory walk that passed every filter. Whether it is a text file
+// is only known once it is read.
+type fileCandidate struct {
+	path         string // Absolute path
+	relativePath string // Path relative to the working directory
+	root         int    // Index of the include directory the file was found in
+	pattern      int    // Index of the first include pattern the file matched, or 0 without include patterns
+	size         int64
+	modTime      time.Time
+}
+
+// collectFiles walks every include directory and returns the files to concatenate, in walk order.
+func collectFiles(opts *options) ([]fileCandidate, error) {
+	var candidates []fileCandidate
+
+	// Remember the targets walked when symbolic links are followed, to skip cycles and targets reached twice
+	walk := &walkState{}
+	if opts.followSymlinks != "skip" {
+		walk.visited = make(visitedTargets)
+		walk.linked = make(map[string]bool)
+	}
+
+	for root, dir := range opts.directories {
 		// Resolve the absolute path of the directory
 		absDir, err := filepath.Abs(filepath.Join(initialWorkingDir, dir))
 		if err != nil {
-			return fmt.Errorf("error resolving absolute path of directory %s: %v", dir, err)
+			return nil, fmt.Errorf("error resolving absolute path of directory %s: %v", dir, err)
 		}
 
 		// Check if the directory exists
 		info, err := os.Stat(absDir)
 		if err != nil {
 			if os.IsNotExist(err) {
-				if verbose {
-					fmt.Printf("Directory does not exist: %s\n", absDir)
+				if opts.verbose {
+					opts.logf("Directory does not exist: %s\n", absDir)
 				}
 				continue
 			}
-			return fmt.Errorf("error accessing directory %s: %v", absDir, err)
+			return nil, fmt.Errorf("error accessing directory %s: %v", absDir, err)
 		}
 		if !info.IsDir() {
-			if verbose {
-				fmt.Printf("Not a directory, skipping: %s\n", absDir)
+			if opts.verbose {
+				opts.logf("Not a directory, skipping: %s\n", absDir)
 			}
 			continue
 		}
+		if walk.seen(absDir, info, false, opts) {
+			continue
+		}
 
-		filesProcessed, err := processDirectory(absDir, &outputFile, outputFilePath, excludedPaths, excludedDirs, includeExts, excludeExts, includePatterns, excludePatterns, verbose)
+		// Load the ignore rules declared above the directory
+		var ignores *ignoreMatcher
+		if opts.useIgnoreFiles {
+			ignores, err = newIgnoreMatcher(absDir)
+			if err != nil {
+				return nil, fmt.Errorf("error loading ignore files for %s: %v", dir, err)
+			}
+		}
+
+		files, err := processDirectory(absDir, root, opts, ignores, walk)
 		if err != nil {
-			return fmt.Errorf("error processing directory %s: %v", dir, err)
+			return nil, fmt.Errorf("error processing directory %s: %v", dir, err)
 		}
-		if !filesProcessed && verbose {
+		if len(files) == 0 && opts.verbose {
 			relativeDir, err := filepath.Rel(initialWorkingDir, absDir)
 			if err != nil || relativeDir == "." {
 				relativeDir = dir
 			}
-			fmt.Printf("No text files found in %s\n", relativeDir)
-		} else if filesProcessed {
-			anyFilesProcessed = true
+			opts.logf("No text files found in %s\n", relativeDir)
 		}
+		candidates = append(candidates, files...)
 	}
 
-	if !anyFilesProcessed && verbose {
-		fmt.Println("No text files found in any of the directories.")
-	} else if anyFilesProcessed {
-		// Compute relative path of the output file for display
-		relativeOutputPath, err := filepath.Rel(initialWorkingDir, outputFilePath)
+	printSensitiveFiles(opts, walk.sensitive)
+	return walk.dropSuperseded(candidates), nil
+}
+
+// output tracks the destination of the concatenated content while the files are written.
+// The destination is only opened once the first file is written.
+type output struct {
+	file      *os.File
+	formatter outputFormatter
+	tree      string // Directory tree written after the header, if requested
+}
+
+// open opens the destination and writes the header of the output format, followed by the directory tree.
+func (out *output) open(opts *options) error {
+	file, err := openOutputFile(opts)
+	if err != nil {
+		return err
+	}
+	out.file = file
+	if err := out.formatter.writeHeader(out.file); err != nil {
+		return fmt.Errorf("error writing output header: %v", err)
+	}
+	if out.tree != "" {
+		if err := out.formatter.writeTree(out.file, out.tree); err != nil {
+			return fmt.Errorf("error writing directory tree: %v", err)
+		}
+	}
+	return nil
+}
+
+// openOutputFile opens the destination of the concatenated content. In append mode the output file is opened for
+// appending, with a warning if it already has content. In overwrite mode a temporary file is created next to the
+// output file; concatenateFiles renames it over the output file once the run completes.
+func openOutputFile(opts *options) (*os.File, error) {
+	if opts.toStdout {
+		return os.Stdout, nil
+	}
+
+	if opts.appendOutput {
+		if info, err := os.Stat(opts.outputFilePath); err == nil && info.Size() > 0 {
+			relativeOutputPath, err := filepath.Rel(initialWorkingDir, opts.outputFilePath)
+			if err != nil {
+				relativeOutputPath = opts.outputFilePath // Fallback to absolute path
+			}
+			opts.logf("Warning: appending to non-empty output file %s\n", relativeOutputPath)
+		}
+		outputFile, err := os.OpenFile(opts.outputFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			relativeOutputPath = outputFilePath // Fallback to absolute path
+			return nil, fmt.Errorf("error creating/opening output file: %v", err)
 		}
-		fmt.Printf("Files concatenated successfully into %s\n", relativeOutputPath)
+		return outputFile, nil
 	}
 
-	return nil
+	return createTempOutputFile(opts.outputFilePath)
 }
 
-// processDirectory recursively reads files in the directory and its subdirectories.
-// It returns a bool indicating whether any text files were processed.
-func processDirectory(dir string, outputFile **os.File, outputFilePath string, excludedPaths map[string]struct{}, excludedDirs map[string]struct{}, includeExts, excludeExts []string, includePatterns, excludePatterns []*regexp.Regexp, verbose bool) (bool, error) {
-	filesProcessed := false
+// createTempOutputFile creates the temporary file an output is written to before it is renamed over path.
+// The temporary file is hidden so that it is never picked up by the directory walk.
+func createTempOutputFile(path string) (*os.File, error) {
+	outputFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary output file: %v", err)
+	}
+	if err := outputFile.Chmod(0644); err != nil {
+		outputFile.Close()
+		os.Remove(outputFile.Name())
+		return nil, fmt.Errorf("error setting permissions of temporary output file: %v", err)
+	}
+	return outputFile, nil
+}
+
+// processDirectory recursively walks the directory and its subdirectories and returns the text files to include.
+// Entries matched by the ignore files in effect are skipped. root is the index of the include directory being walked,
+// and walk holds the state shared by the whole walk.
+func processDirectory(dir string, root int, opts *options, ignores *ignoreMatcher, walk *walkState) ([]fileCandidate, error) {
+	var candidates []fileCandidate
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return false, fmt.Errorf("error reading directory %s: %v", dir, err)
+		return nil, fmt.Errorf("error reading directory %s: %v", dir, err)
 	}
 
-	// Track whether any text files were found in subdirectories
-	subdirFilesProcessed := false
+	// Add the ignore files declared in this directory
+	ignores, err = ignores.forDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, entry := range entries {
 		name := entry.Name()
 		path := filepath.Join(dir, name)
+		isDir := entry.IsDir()
+
+		// A symbolic link is filtered like its target, which decides whether it is a file or a directory
+		var linkTarget os.FileInfo
+		if entry.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				if opts.verbose {
+					opts.logf("Skipping broken symlink %s: %v\n", relativeToWorkingDir(path), err)
+				}
+				continue
+			}
+			linkTarget = info
+			isDir = info.IsDir()
+		}
 
-		// Skip hidden files and directories
-		if isHidden(name) {
+		// Never descend into version control internals, even with -hidden
+		if isVCSDir(name) {
+			if opts.verbose {
+				opts.logf("Skipping version control directory: %s\n", relativeToWorkingDir(path))
+			}
+			continue
+		}
+
+		// Skip hidden files and directories, unless -hidden is set or an include pattern names them
+		if isHidden(name) && !opts.hidden && !opts.includesHidden(path, name, isDir) {
 			continue
 		}
 
 		// Skip excluded files and directories based on full path
-		if _, excluded := excludedPaths[path]; excluded {
-			if verbose {
-				fmt.Printf("Skipping excluded path: %s\n", path)
+		if _, excluded := opts.excludedPaths[path]; excluded {
+			if opts.verbose {
+				opts.logf("Skipping excluded path: %s\n", path)
+			}
+			continue
+		}
+
+		// Skip the chunks of a previous split run
+		if isChunkFile(path, opts.outputFilePath) {
+			if opts.verbose {
+				opts.logf("Skipping output chunk: %s\n", path)
+			}
+			continue
+		}
+
+		// Skip files and directories matched by an ignore file
+		if rule, ignored := ignores.match(path, isDir); ignored {
+			if opts.verbose {
+				relPath, err := filepath.Rel(initialWorkingDir, path)
+				if err != nil {
+					relPath = path // Fallback to absolute path
+				}
+				kind := "file"
+				if isDir {
+					kind = "directory"
+				}
+				opts.logf("Skipping %s %s: ignored by %s:%d\n", kind, relPath, rule.source, rule.line)
 			}
 			continue
 		}
 
 		// Check if the current directory is in the excluded directories
-		if entry.IsDir() {
+		if isDir {
 			relPath, err := filepath.Rel(initialWorkingDir, path)
 			if err != nil {
 				relPath = path // Fallback to absolute path
 			}
-			// Normalize the relative path for comparison
-			normalizedRelPath := filepath.ToSlash(relPath)
-			if _, excluded := excludedDirs[normalizedRelPath]; excluded {
-				if verbose {
-					fmt.Printf("Skipping excluded directory: %s\n", relPath)
+			if match := matchGlobs(relPath, opts.excludedDirs); match >= 0 {
+				if opts.verbose {
+					opts.logf("Skipping excluded directory: %s (matches %q)\n", relPath, opts.excludedDirs[match].pattern)
+				}
+				continue
+			}
+			if match := matchGlobs(relPath, opts.defaultExcludes); match >= 0 {
+				if opts.verbose {
+					opts.logf("Skipping excluded directory: %s (built-in exclusion %q)\n", relPath, opts.defaultExcludes[match].pattern)
+				}
+				continue
+			}
+			if match := matchGlobs(relPath, opts.excludePaths); match >= 0 {
+				if opts.verbose {
+					opts.logf("Skipping directory %s: matches exclude path pattern %q\n", relPath, opts.excludePaths[match].pattern)
 				}
 				continue
 			}
 
+			// Follow linked directories only with -follow-symlinks=all, and walk each target once
+			if linkTarget != nil && !opts.followsSymlink(path, linkTarget) {
+				continue
+			}
+			if walk.visited != nil {
+				info := linkTarget
+				if info == nil {
+					if info, err = entry.Info(); err != nil {
+						return nil, fmt.Errorf("error reading directory %s: %v", path, err)
+					}
+				}
+				if walk.seen(path, info, walk.viaLink(path, linkTarget), opts) {
+					continue
+				}
+			}
+
 			// Recursively process subdirectories
-			subdirProcessed, err := processDirectory(path, outputFile, outputFilePath, excludedPaths, excludedDirs, includeExts, excludeExts, includePatterns, excludePatterns, verbose)
+			subdirFiles, err := processDirectory(path, root, opts, ignores, walk)
 			if err != nil {
-				return false, err
+				return nil, err
 			}
-			if !subdirProcessed && verbose {
+			if len(subdirFiles) == 0 && opts.verbose {
 				relativeDir, err := filepath.Rel(initialWorkingDir, path)
 				if err != nil || relativeDir == "." {
 					relativeDir = path
 				}
-				fmt.Printf("No text files found in %s\n", relativeDir)
-			} else if subdirProcessed {
-				subdirFilesProcessed = true
+				opts.logf("No text files found in %s\n", relativeDir)
 			}
+			candidates = append(candidates, subdirFiles...)
 		} else {
 			// Determine relative path once for both processing and exclusion messages
 			relativePath, err := filepath.Rel(initialWorkingDir, path)
@@ -257,80 +846,107 @@ func processDirectory(dir string, outputFile **os.File, outputFilePath string, e
 				relativePath = path // Fallback to absolute path if cannot compute relative path
 			}
 
+			// Check if the relative path matches any of the exclude path globs
+			if match := matchGlobs(relativePath, opts.excludePaths); match >= 0 {
+				if opts.verbose {
+					opts.logf("Skipping file %s: matches exclude path pattern %q\n", relativePath, opts.excludePaths[match].pattern)
+				}
+				continue
+			}
+
+			// Check if the relative path matches the include path globs, if any
+			if len(opts.includePaths) > 0 {
+				match := matchGlobs(relativePath, opts.includePaths)
+				if match < 0 {
+					if opts.verbose {
+						opts.logf("Skipping file %s: does not match any include path pattern\n", relativePath)
+					}
+					continue
+				}
+				if opts.verbose {
+					opts.logf("Including file %s: matches include path pattern %q\n", relativePath, opts.includePaths[match].pattern)
+				}
+			}
+
 			// Check if the file matches any of the exclude patterns
-			if matchesPatterns(name, excludePatterns) {
-				if verbose {
-					fmt.Printf("Skipping file %s: matches exclude pattern\n", relativePath)
+			if matchesPatterns(name, opts.excludePatterns) {
+				if opts.verbose {
+					opts.logf("Skipping file %s: matches exclude pattern\n", relativePath)
 				}
 				continue
 			}
 
 			// Check if the file matches the include patterns, if any
-			if len(includePatterns) > 0 && !matchesPatterns(name, includePatterns) {
-				if verbose {
-					fmt.Printf("Skipping file %s: does not match include pattern\n", relativePath)
+			pattern := firstMatch(name, opts.includePatterns)
+			if len(opts.includePatterns) > 0 && pattern < 0 {
+				if opts.verbose {
+					opts.logf("Skipping file %s: does not match include pattern\n", relativePath)
 				}
 				continue
 			}
 
-			// Check if the file should be included based on extensions
-			if shouldIncludeFile(path, includeExts, excludeExts) && isTextFile(path) {
-				// Open output file if not already opened
-				if *outputFile == nil {
-					var err error
-					*outputFile, err = os.OpenFile(outputFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-					if err != nil {
-						return false, fmt.Errorf("error creating/opening output file: %v", err)
+			// Never include files likely to hold credentials, unless -allow-sensitive is set
+			if walk.skipsSensitive(relativePath, opts) {
+				continue
+			}
+
+			// Check if the file should be included based on extensions. Its content is checked once it is read.
+			if shouldIncludeFile(path, opts.includeExts, opts.excludeExts) {
+				// Follow linked files unless -follow-symlinks=skip, and read each target once
+				info := linkTarget
+				if info != nil && !opts.followsSymlink(path, info) {
+					continue
+				}
+				if info == nil {
+					if info, err = entry.Info(); err != nil {
+						opts.logf("Error processing file %s: %v\n", relativePath, err)
+						continue
 					}
 				}
-
-				// Processing status in a single line
-				fmt.Printf("Processing %s ... ", relativePath)
-
-				// Write file content to the output file
-				err = writeFileContent(*outputFile, path, relativePath)
-				if err != nil {
-					fmt.Printf("Error\n")
-					fmt.Printf("Error processing file %s: %v\n", relativePath, err)
-				} else {
-					// Indicate completion on the same line
-					fmt.Printf("Done\n")
+				if walk.seen(path, info, walk.viaLink(path, linkTarget), opts) {
+					continue
 				}
-
-				filesProcessed = true
+				candidates = append(candidates, fileCandidate{
+					path:         path,
+					relativePath: relativePath,
+					root:         root,
+					pattern:      max(pattern, 0),
+					size:         info.Size(),
+					modTime:      info.ModTime(),
+				})
 			} else {
 				// Determine reason for exclusion
 				ext := strings.ToLower(filepath.Ext(path))
 				skipped := false
 
-				if len(includeExts) > 0 {
+				if len(opts.includeExts) > 0 {
 					// If includeExts is specified and file is not included
 					included := false
-					for _, includeExt := range includeExts {
+					for _, includeExt := range opts.includeExts {
 						if ext == includeExt {
 							included = true
 							break
 						}
 					}
 					if !included {
-						if verbose {
-							fmt.Printf("Skipping file %s: does not match include extensions\n", relativePath)
+						if opts.verbose {
+							opts.logf("Skipping file %s: does not match include extensions\n", relativePath)
 						}
 						skipped = true
 					}
 				}
-				if !skipped && len(excludeExts) > 0 {
+				if !skipped && len(opts.excludeExts) > 0 {
 					// If excludeExts is specified and file is excluded
 					excluded := false
-					for _, excludeExt := range excludeExts {
+					for _, excludeExt := range opts.excludeExts {
 						if ext == excludeExt {
 							excluded = true
 							break
 						}
 					}
 					if excluded {
-						if verbose {
-							fmt.Printf("Skipping file %s: excluded by extension %s\n", relativePath, ext)
+						if opts.verbose {
+							opts.logf("Skipping file %s: excluded by extension %s\n", relativePath, ext)
 						}
 						skipped = true
 					}
@@ -340,22 +956,22 @@ func processDirectory(dir string, outputFile **os.File, outputFilePath string, e
 		}
 	}
 
-	// If no files were processed in this directory or its subdirectories
-	if !filesProcessed && !subdirFilesProcessed {
-		return false, nil
-	}
-
-	return true, nil
+	return candidates, nil
 }
 
 // matchesPatterns checks if the filename matches any of the given patterns.
 func matchesPatterns(filename string, patterns []*regexp.Regexp) bool {
-	for _, re := range patterns {
+	return firstMatch(filename, patterns) >= 0
+}
+
+// firstMatch returns the index of the first pattern the filename matches, or -1 if none does.
+func firstMatch(filename string, patterns []*regexp.Regexp) int {
+	for i, re := range patterns {
 		if re.MatchString(filename) {
-			return true
+			return i
 		}
 	}
-	return false
+	return -1
 }
 
 // shouldIncludeFile determines if a file should be included based on the provided include and exclude extensions.
@@ -390,30 +1006,8 @@ func shouldIncludeFile(filePath string, includeExts, excludeExts []string) bool
 }
 
 // writeFileContent reads a file and writes its content to the output file in the specified format.
-func writeFileContent(outputFile *os.File, filePath, relativePath string) error {
-	// Open the file for reading
-	inputFile, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("error opening file %s: %v", filePath, err)
-	}
-	defer inputFile.Close()
-
-	// Write the file path to the output file
-	if _, err := fmt.Fprintf(outputFile, "// File: %s\n\n", relativePath); err != nil {
-		return fmt.Errorf("error writing file path to output file: %v", err)
-	}
-
-	// Copy the file content to the output file
-	if _, err := io.Copy(outputFile, inputFile); err != nil {
-		return fmt.Errorf("error copying content from %s: %v", filePath, err)
-	}
-
-	// Write one newline to separate files
-	if _, err := outputFile.WriteString("\n"); err != nil {
-		return fmt.Errorf("error writing separator to output file: %v", err)
-	}
-
-	return nil
+func writeFileContent(outputFile io.Writer, formatter outputFormatter, file loadedFile) error {
+	return formatter.writeFile(outputFile, file.entry())
 }
 
 // isHidden checks if a file or directory is hidden (starts with a dot).
@@ -421,38 +1015,45 @@ func isHidden(name string) bool {
 	return strings.HasPrefix(name, ".")
 }
 
-// isTextFile determines if a file is a text file using net/http.DetectContentType.
-func isTextFile(path string) bool {
-	file, err := os.Open(path)
+// vcsDirs are the version control directories that are never processed.
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// isVCSDir checks if an entry is a version control directory, or a .git file linking to one.
+func isVCSDir(name string) bool {
+	return slices.Contains(vcsDirs, name)
+}
+
+// includesHidden reports whether an include pattern names a hidden entry explicitly, so that it is processed
+// without -hidden. The name must appear in an include path glob segment starting with a dot, or match an include
+// file pattern anchored on a leading dot such as "^\.env". A hidden file must also match the glob; a hidden
+// directory is entered when the glob may match a file inside it.
+func (opts *options) includesHidden(path, name string, isDir bool) bool {
+	relPath, err := filepath.Rel(initialWorkingDir, path)
 	if err != nil {
-		// If we can't open it, assume it's not text
 		return false
 	}
-	defer file.Close()
-
-	// Read the first 512 bytes for content detection
-	buffer := make([]byte, bufferSize)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
+	for _, glob := range opts.includePaths {
+		if !glob.namesHidden(name) {
+			continue
+		}
+		if isDir && glob.mayMatchBelow(relPath) || !isDir && glob.regex.MatchString(filepath.ToSlash(relPath)) {
+			return true
+		}
+	}
+	if isDir {
 		return false
 	}
-	buffer = buffer[:n]
-
-	// Handle empty files as text files
-	if n == 0 {
-		return true
+	for _, re := range opts.includePatterns {
+		if strings.HasPrefix(re.String(), `^\.`) && re.MatchString(name) {
+			return true
+		}
 	}
-
-	contentType := http.DetectContentType(buffer)
-	return strings.HasPrefix(contentType, "text/") ||
-		contentType == "application/json" ||
-		contentType == "application/javascript" ||
-		contentType == "application/xml"
+	return false
 }
 
 func main() {
 	if err := run(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -471,51 +1072,23 @@ func run() error {
 		return fmt.Errorf("Error getting executable path: %v", err)
 	}
 
-	// Parse command-line arguments
-	outputFileName, directories, includeExts, excludeExts, includePatterns, excludePatterns, excludeDirs, verbose, err := parseArguments()
+	// Parse command-line arguments and the configuration file
+	cfg, err := parseArguments()
 	if err != nil {
 		return err
 	}
 
-	// Get the absolute path of the output file
-	outputFilePath, err := filepath.Abs(outputFileName)
-	if err != nil {
-		return fmt.Errorf("Error getting absolute path of output file: %v", err)
-	}
-
-	// Get the list of paths to exclude (script and output file)
-	excludedPaths := getExcludedPaths(outputFilePath, scriptFilePath)
-
-	// Prepare excluded directories map
-	excludedDirsMap := make(map[string]struct{})
-	for _, dir := range excludeDirs {
-		// Normalize directory paths to use forward slashes
-		normalizedDir := filepath.ToSlash(dir)
-		excludedDirsMap[normalizedDir] = struct{}{}
+	if cfg.PrintConfig {
+		return printConfig(cfg)
 	}
 
-	// Compile include patterns into regular expressions
-	var includeRegexps []*regexp.Regexp
-	for _, pattern := range includePatterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			return fmt.Errorf("Invalid include-file-pattern %q: %v", pattern, err)
-		}
-		includeRegexps = append(includeRegexps, re)
-	}
-
-	// Compile exclude patterns into regular expressions
-	var excludeRegexps []*regexp.Regexp
-	for _, pattern := range excludePatterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			return fmt.Errorf("Invalid exclude-file-pattern %q: %v", pattern, err)
-		}
-		excludeRegexps = append(excludeRegexps, re)
+	opts, err := newOptions(cfg, scriptFilePath)
+	if err != nil {
+		return err
 	}
 
 	// Concatenate files from the directories
-	if err := concatenateFiles(outputFilePath, directories, excludedPaths, excludedDirsMap, includeExts, excludeExts, includeRegexps, excludeRegexps, verbose); err != nil {
+	if err := concatenateFiles(opts); err != nil {
 		return err
 	}
 	return nil
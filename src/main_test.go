@@ -4,9 +4,11 @@ package main
 
 import (
 	"flag"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -22,37 +24,42 @@ func TestParseArguments(t *testing.T) {
 		"-exclude-dir", "vendor",
 		"-exclude-file-pattern", ".*_test\\.go$",
 		"-verbose",
+		"-no-ignore-files",
 	}
 
 	// Reset flag defaults and parse
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	output, _, includeExt, excludeExt, includePatterns, excludePatterns, excludeDir, verbose, err := parseArguments()
+	initialWorkingDir = t.TempDir()
+	cfg, err := parseArguments()
 
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if output != "test_output.txt" {
-		t.Errorf("Expected output 'test_output.txt', got %s", output)
+	if cfg.Output != "test_output.txt" {
+		t.Errorf("Expected output 'test_output.txt', got %s", cfg.Output)
 	}
-	if len(includeExt) != 2 || includeExt[0] != ".go" || includeExt[1] != ".md" {
-		t.Errorf("Expected include extensions [.go .md], got %v", includeExt)
+	if len(cfg.IncludeExt) != 2 || cfg.IncludeExt[0] != ".go" || cfg.IncludeExt[1] != ".md" {
+		t.Errorf("Expected include extensions [.go .md], got %v", cfg.IncludeExt)
 	}
-	if len(excludeExt) != 1 || excludeExt[0] != ".test" {
-		t.Errorf("Expected exclude extension '.test', got %v", excludeExt)
+	if len(cfg.ExcludeExt) != 1 || cfg.ExcludeExt[0] != ".test" {
+		t.Errorf("Expected exclude extension '.test', got %v", cfg.ExcludeExt)
 	}
-	if len(excludeDir) != 1 || excludeDir[0] != "vendor" {
-		t.Errorf("Expected exclude directory 'vendor', got %v", excludeDir)
+	if len(cfg.ExcludeDir) != 1 || cfg.ExcludeDir[0] != "vendor" {
+		t.Errorf("Expected exclude directory 'vendor', got %v", cfg.ExcludeDir)
 	}
-	if len(includePatterns) != 1 || includePatterns[0] != "^main\\.go$" {
-		t.Errorf("Expected include pattern '^main\\.go$', got %v", includePatterns)
+	if len(cfg.IncludeFilePattern) != 1 || cfg.IncludeFilePattern[0] != "^main\\.go$" {
+		t.Errorf("Expected include pattern '^main\\.go$', got %v", cfg.IncludeFilePattern)
 	}
-	if len(excludePatterns) != 1 || excludePatterns[0] != ".*_test\\.go$" {
-		t.Errorf("Expected exclude pattern '.*_test\\.go$', got %v", excludePatterns)
+	if len(cfg.ExcludeFilePattern) != 1 || cfg.ExcludeFilePattern[0] != ".*_test\\.go$" {
+		t.Errorf("Expected exclude pattern '.*_test\\.go$', got %v", cfg.ExcludeFilePattern)
 	}
-	if !verbose {
+	if !cfg.Verbose {
 		t.Errorf("Expected verbose to be true, got false")
 	}
+	if !cfg.NoIgnoreFiles {
+		t.Errorf("Expected ignore files to be disabled, got enabled")
+	}
 }
 
 // TestShouldIncludeFile checks the file inclusion logic based on file extensions.
@@ -120,8 +127,10 @@ func TestMatchesPatterns(t *testing.T) {
 	}
 }
 
-// TestIsTextFile verifies text file detection using MIME type.
-func TestIsTextFile(t *testing.T) {
+// TestTextFileDetection verifies text file detection from the content of a file.
+func TestTextFileDetection(t *testing.T) {
+	detector := newContentDetector(defaultSampleSize, nil, nil)
+
 	// Create temporary text file
 	textFile, err := os.CreateTemp("", "test.txt")
 	if err != nil {
@@ -130,7 +139,7 @@ func TestIsTextFile(t *testing.T) {
 	defer os.Remove(textFile.Name())
 	textFile.WriteString("This is a test text file.")
 
-	if !isTextFile(textFile.Name()) {
+	if file := readCandidate(fileCandidate{path: textFile.Name()}, detector); file.err != nil || file.binary {
 		t.Error("Expected text file detection to be true for test.txt")
 	}
 
@@ -142,7 +151,7 @@ func TestIsTextFile(t *testing.T) {
 	defer os.Remove(binaryFile.Name())
 	binaryFile.Write([]byte{0x00, 0xFF, 0x00, 0xFF})
 
-	if isTextFile(binaryFile.Name()) {
+	if file := readCandidate(fileCandidate{path: binaryFile.Name()}, detector); !file.binary {
 		t.Error("Expected text file detection to be false for test.bin")
 	}
 }
@@ -158,7 +167,8 @@ func TestWriteFileContent(t *testing.T) {
 	defer os.Remove(outputFile.Name())
 
 	// Run writeFileContent
-	err := writeFileContent(outputFile, contentFile.Name(), "content.txt")
+	file := readCandidate(fileCandidate{path: contentFile.Name(), relativePath: "content.txt"}, newContentDetector(defaultSampleSize, nil, nil))
+	err := writeFileContent(outputFile, plainFormatter{}, file)
 	if err != nil {
 		t.Fatalf("Error writing file content: %v", err)
 	}
@@ -220,7 +230,17 @@ func TestConcatenateFiles(t *testing.T) {
 	}
 
 	// Run concatenateFiles with the relative directory name
-	err := concatenateFiles(outputFile, []string{dirName}, excludedPaths, nil, []string{".go", ".md"}, nil, includeRegexps, excludeRegexps, true)
+	opts := &options{
+		outputFilePath:  outputFile,
+		directories:     []string{dirName},
+		excludedPaths:   excludedPaths,
+		includeExts:     []string{".go", ".md"},
+		includePatterns: includeRegexps,
+		excludePatterns: excludeRegexps,
+		useIgnoreFiles:  true,
+		verbose:         true,
+	}
+	err := concatenateFiles(opts)
 	if err != nil {
 		t.Fatalf("Error concatenating files: %v", err)
 	}
@@ -234,3 +254,171 @@ func TestConcatenateFiles(t *testing.T) {
 		t.Errorf("Expected concatenated output:\n%s\nGot:\n%s", expected, data)
 	}
 }
+
+// TestConcatenateFilesToStdout validates that the content is streamed to stdout while status messages go to stderr.
+func TestConcatenateFilesToStdout(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.WriteFile(filepath.Join(parentDir, "main.go"), []byte("Content of main.go"), 0644)
+
+	// Capture stdout and stderr
+	stdoutReader, stdoutWriter, _ := os.Pipe()
+	stderrReader, stderrWriter, _ := os.Pipe()
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutWriter, stderrWriter
+
+	opts := &options{directories: []string{"."}, toStdout: true, verbose: true}
+	err := concatenateFiles(opts)
+
+	os.Stdout, os.Stderr = originalStdout, originalStderr
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	stdout, _ := io.ReadAll(stdoutReader)
+	stderr, _ := io.ReadAll(stderrReader)
+	expected := "// File: main.go\n\nContent of main.go\n"
+	if string(stdout) != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+	if !strings.Contains(string(stderr), "Processing main.go ... Done") {
+		t.Errorf("Expected progress messages on stderr, got:\n%s", stderr)
+	}
+}
+
+// TestConcatenateFilesOutputMode validates that overwrite mode replaces the output file and append mode adds to it.
+func TestConcatenateFilesOutputMode(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	dir := filepath.Join(parentDir, "testdir")
+	os.Mkdir(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("Content of main.go"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+	os.WriteFile(outputFile, []byte("stale content\n"), 0644)
+
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"testdir"},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+	}
+	expected := "// File: testdir/main.go\n\nContent of main.go\n"
+
+	// Overwrite mode, run twice
+	for i := 0; i < 2; i++ {
+		if err := concatenateFiles(opts); err != nil {
+			t.Fatalf("Error concatenating files: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(outputFile)
+	if string(data) != expected {
+		t.Errorf("Expected overwritten output:\n%s\nGot:\n%s", expected, data)
+	}
+
+	// No temporary file should be left behind
+	entries, _ := os.ReadDir(parentDir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only the output file and test directory, got %d entries", len(entries))
+	}
+
+	// Append mode
+	opts.appendOutput = true
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+	data, _ = os.ReadFile(outputFile)
+	if string(data) != expected+expected {
+		t.Errorf("Expected appended output:\n%s\nGot:\n%s", expected+expected, data)
+	}
+}
+
+// TestExcludeDirAtAnyDepth checks that excluded directory names and globs match at any depth,
+// and that the built-in exclusions can be turned off.
+func TestExcludeDirAtAnyDepth(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	for _, path := range []string{"app/main.go", "app/vendor/lib.go", "app/build-cache/x.go", "packages/ui/dist/ui.js", "packages/ui/src/ui.js", "web/node_modules/dep.js"} {
+		fullPath := filepath.Join(parentDir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(path), 0644)
+	}
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	for _, noDefaultExcludes := range []bool{false, true} {
+		cfg := defaultConfig()
+		cfg.Output = outputFile
+		cfg.ExcludeDir = []string{"vendor", "build*", "packages/*/dist/"}
+		cfg.NoDefaultExcludes = noDefaultExcludes
+		normalizeConfig(&cfg)
+		opts, err := newOptions(cfg, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := concatenateFiles(opts); err != nil {
+			t.Fatalf("Error concatenating files: %v", err)
+		}
+
+		data, _ := os.ReadFile(outputFile)
+		expected := "// File: app/main.go\n\napp/main.go\n// File: packages/ui/src/ui.js\n\npackages/ui/src/ui.js\n"
+		if noDefaultExcludes {
+			expected += "// File: web/node_modules/dep.js\n\nweb/node_modules/dep.js\n"
+		}
+		if string(data) != expected {
+			t.Errorf("No default excludes %v: expected output:\n%s\nGot:\n%s", noDefaultExcludes, expected, data)
+		}
+	}
+}
+
+// TestHiddenFiles checks that hidden entries are skipped by default, included with -hidden or when an include
+// pattern names them, and that version control directories are always skipped. Include patterns that only
+// match hidden entries through wildcards do not include them.
+func TestHiddenFiles(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	for _, path := range []string{".git/config", ".github/workflows/ci.yml", ".env.example", ".hidden.go", ".cache/x/gen.go", "main.go"} {
+		fullPath := filepath.Join(parentDir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(path), 0644)
+	}
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	workflows, _ := compilePathGlobs([]string{".github/workflows/*.yml"}, false)
+	goFiles, _ := compilePathGlobs([]string{"**/*.go"}, false)
+	tests := []struct {
+		hidden          bool
+		includePaths    []pathGlob
+		includePatterns []*regexp.Regexp
+		expected        []string
+	}{
+		{false, nil, nil, []string{"main.go"}},
+		{true, nil, nil, []string{".cache/x/gen.go", ".env.example", ".github/workflows/ci.yml", ".hidden.go", "main.go"}},
+		{false, workflows, nil, []string{".github/workflows/ci.yml"}},
+		{false, goFiles, nil, []string{"main.go"}},
+		{false, nil, []*regexp.Regexp{regexp.MustCompile(`\.go$`)}, []string{"main.go"}},
+		{false, nil, []*regexp.Regexp{regexp.MustCompile(`^\.env`)}, []string{".env.example"}},
+	}
+
+	for i, test := range tests {
+		opts := &options{
+			outputFilePath:  outputFile,
+			directories:     []string{"."},
+			excludedPaths:   map[string]struct{}{outputFile: {}},
+			includePaths:    test.includePaths,
+			includePatterns: test.includePatterns,
+			hidden:          test.hidden,
+		}
+		if err := concatenateFiles(opts); err != nil {
+			t.Fatalf("Error concatenating files: %v", err)
+		}
+
+		var expected string
+		for _, path := range test.expected {
+			expected += "// File: " + filepath.FromSlash(path) + "\n\n" + path + "\n"
+		}
+		data, _ := os.ReadFile(outputFile)
+		if string(data) != expected {
+			t.Errorf("Case %d: expected output:\n%s\nGot:\n%s", i, expected, data)
+		}
+	}
+}
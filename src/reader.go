@@ -0,0 +1,211 @@
+// File: src/reader.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// loadedFile is a candidate file once read. Binary files are detected from the first bytes and not read further.
+type loadedFile struct {
+	fileCandidate
+	content   []byte
+	binary    bool
+	reason    string // Why the file was classified as binary
+	encoding  string // Encoding the file was read in, before being transcoded to UTF-8
+	oversized bool   // Whether the file is skipped for exceeding -max-file-size or -max-file-lines
+	limit     string // Why the file was skipped or how it was truncated by the file size limits
+	secrets   []secretFinding
+	err       error
+}
+
+// entry returns the file ready to be written to the output. The header of a transcoded file names its encoding.
+func (file loadedFile) entry() fileEntry {
+	entry := fileEntry{path: file.relativePath, absPath: file.path, modTime: file.modTime, content: file.content}
+	if file.encoding != "utf-8" {
+		entry.encoding = file.encoding
+	}
+	return entry
+}
+
+// readFiles reads the candidates with a pool of jobs workers and delivers them on the returned channel in
+// candidate order, so the output does not depend on which read finishes first. Workers never run more than
+// a few files ahead of the consumer, which bounds the memory held by files read but not yet written.
+// Text files are delivered transcoded to UTF-8 and within the file size limits. Closing done stops the pipeline early; otherwise the channel
+// must be drained.
+func readFiles(candidates []fileCandidate, opts *options, done <-chan struct{}) <-chan loadedFile {
+	detector := newContentDetector(opts.sampleSize, opts.textExts, opts.binaryExts)
+	jobs := max(opts.jobs, 1)
+	results := make([]chan loadedFile, len(candidates))
+	for i := range results {
+		results[i] = make(chan loadedFile, 1)
+	}
+	indexes := make(chan int)
+	window := make(chan struct{}, jobs*4)
+	files := make(chan loadedFile)
+
+	// Hand out the candidates in order, waiting for the consumer when the window is full
+	go func() {
+		defer close(indexes)
+		for i := range candidates {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			indexes <- i
+		}
+	}()
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range indexes {
+				results[i] <- loadCandidate(candidates[i], detector, opts)
+			}
+		}()
+	}
+
+	// Deliver the results in candidate order
+	go func() {
+		defer close(files)
+		for i := range candidates {
+			var file loadedFile
+			select {
+			case file = <-results[i]:
+			case <-done:
+				return
+			}
+			select {
+			case files <- file:
+			case <-done:
+				return
+			}
+			<-window
+		}
+	}()
+
+	return files
+}
+
+// readCandidate reads a candidate file once: the first bytes are sampled to detect binary files and the
+// encoding of text files, and the same buffer is then filled with the rest of the content. Files classified
+// as binary by their extension are not read, and files classified as text are only sampled for their encoding.
+func readCandidate(candidate fileCandidate, detector *contentDetector) loadedFile {
+	file := loadedFile{fileCandidate: candidate}
+	kind, byExtension := detector.classifyByExtension(candidate.path)
+	if kind.binary {
+		file.binary, file.reason = true, kind.reason
+		return file
+	}
+
+	f, err := os.Open(candidate.path)
+	if err != nil {
+		file.err = err
+		return file
+	}
+	defer f.Close()
+
+	buf := bytes.NewBuffer(make([]byte, 0, max(candidate.size+bytes.MinRead, int64(detector.sampleSize))))
+	n, err := io.CopyN(buf, f, int64(detector.sampleSize))
+	if err != nil && err != io.EOF {
+		file.err = err
+		return file
+	}
+	if sampled := classify(buf.Bytes(), err == nil); !byExtension {
+		kind = sampled
+		if kind.binary {
+			file.binary, file.reason = true, kind.reason
+			return file
+		}
+	} else if !sampled.binary {
+		kind.encoding = sampled.encoding
+	}
+	file.encoding = kind.encoding
+	if _, err := buf.ReadFrom(f); err != nil {
+		file.err = err
+		return file
+	}
+
+	// A binary blob may start after the sample
+	if !byExtension && kind.encoding != "utf-16le" && kind.encoding != "utf-16be" {
+		if i := bytes.IndexByte(buf.Bytes()[n:], 0); i >= 0 {
+			file.binary, file.reason = true, fmt.Sprintf("contains a NUL byte at offset %d, after the sampled bytes", n+int64(i))
+			return file
+		}
+	}
+	// Accented Latin-1 characters may also only appear after the sample
+	if file.encoding == "utf-8" && !utf8.Valid(buf.Bytes()) {
+		file.encoding = "latin-1"
+	}
+	file.content = buf.Bytes()
+	return file
+}
+
+// loadFiles drains the read pipeline and returns the text files, reporting the files that were skipped
+// and recording the files skipped, truncated or redacted on the way.
+func loadFiles(files <-chan loadedFile, opts *options, report *fileReport) []loadedFile {
+	var loaded []loadedFile
+	for file := range files {
+		if skipUnreadFile(file, opts) || report.note(file, opts) {
+			continue
+		}
+		loaded = append(loaded, file)
+	}
+	return loaded
+}
+
+// skipUnreadFile reports whether a file could not be read or is binary, logging why it is skipped.
+func skipUnreadFile(file loadedFile, opts *options) bool {
+	if file.err != nil {
+		opts.logf("Error processing file %s: %v\n", file.relativePath, file.err)
+		return true
+	}
+	if file.binary {
+		if opts.verbose {
+			opts.logf("Skipping file %s: not a text file (%s)\n", file.relativePath, file.reason)
+		}
+		return true
+	}
+	return false
+}
+
+// fileReport collects the files skipped or truncated by the file size limits and the files in which secrets
+// were found, for the end-of-run summary.
+type fileReport struct {
+	skipped   []loadedFile
+	truncated []loadedFile
+	redacted  []loadedFile
+}
+
+// note logs and records a file skipped or truncated by the file size limits or holding secrets, and reports
+// whether it is skipped.
+func (report *fileReport) note(file loadedFile, opts *options) bool {
+	if len(file.secrets) > 0 {
+		if opts.verbose {
+			action := "Redacted"
+			if opts.failOnSecrets {
+				action = "Found"
+			}
+			opts.logf("%s %d secrets in %s: %s\n", action, len(file.secrets), file.relativePath, describeSecrets(file.secrets))
+		}
+		report.redacted = append(report.redacted, file)
+	}
+	switch {
+	case file.oversized:
+		if opts.verbose {
+			opts.logf("Skipping file %s: %s\n", file.relativePath, file.limit)
+		}
+		report.skipped = append(report.skipped, file)
+		return true
+	case file.limit != "":
+		if opts.verbose {
+			opts.logf("Truncating file %s: %s\n", file.relativePath, file.limit)
+		}
+		report.truncated = append(report.truncated, file)
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// File: src/reader_test.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestReadFilesOrder checks that files read by several workers are delivered in candidate order.
+func TestReadFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	var candidates []fileCandidate
+	for i := 0; i < 200; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file%03d.txt", i))
+		os.WriteFile(path, []byte(fmt.Sprintf("content %d\n", i)), 0644)
+		candidates = append(candidates, fileCandidate{path: path, relativePath: filepath.Base(path)})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	i := 0
+	for file := range readFiles(candidates, &options{jobs: 8}, done) {
+		if file.err != nil {
+			t.Fatalf("Error reading %s: %v", file.relativePath, file.err)
+		}
+		if expected := fmt.Sprintf("content %d\n", i); string(file.content) != expected {
+			t.Fatalf("Expected file %d to hold %q, got %q", i, expected, file.content)
+		}
+		i++
+	}
+	if i != len(candidates) {
+		t.Errorf("Expected %d files, got %d", len(candidates), i)
+	}
+}
+
+// TestReadCandidate checks that text files are read whole and binary files are detected from their first bytes.
+func TestReadCandidate(t *testing.T) {
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "large.txt")
+	detector := newContentDetector(defaultSampleSize, nil, nil)
+	content := make([]byte, 3*defaultSampleSize+7)
+	for i := range content {
+		content[i] = 'a' + byte(i%26)
+	}
+	os.WriteFile(textPath, content, 0644)
+	binaryPath := filepath.Join(dir, "image.bin")
+	os.WriteFile(binaryPath, []byte{0x00, 0xFF, 0x00, 0xFF}, 0644)
+
+	file := readCandidate(fileCandidate{path: textPath, size: int64(len(content))}, detector)
+	if file.err != nil || file.binary || string(file.content) != string(content) {
+		t.Errorf("Expected the whole text file to be read, got %d bytes (binary %v, error %v)", len(file.content), file.binary, file.err)
+	}
+
+	file = readCandidate(fileCandidate{path: binaryPath}, detector)
+	if !file.binary || file.content != nil {
+		t.Errorf("Expected the binary file to be detected without keeping its content")
+	}
+
+	file = readCandidate(fileCandidate{path: filepath.Join(dir, "missing.txt")}, detector)
+	if file.err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	// A NUL byte after the sample still makes the file binary
+	blobPath := filepath.Join(dir, "blob.txt")
+	os.WriteFile(blobPath, append(content, 0x00, 0x01), 0644)
+	file = readCandidate(fileCandidate{path: blobPath}, detector)
+	if !file.binary || file.reason != fmt.Sprintf("contains a NUL byte at offset %d, after the sampled bytes", len(content)) {
+		t.Errorf("Expected the NUL byte after the sample to be detected, got binary %v (%s)", file.binary, file.reason)
+	}
+}
+
+// TestReadCandidateLatin1AfterSample checks that a file whose first byte invalid in UTF-8 comes after the sample
+// is read as Latin-1 and transcoded.
+func TestReadCandidateLatin1AfterSample(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.txt")
+	content := append(bytes.Repeat([]byte("a"), defaultSampleSize+808), []byte("caf\xe9")...)
+	os.WriteFile(path, content, 0644)
+
+	file := readCandidate(fileCandidate{path: path}, newContentDetector(defaultSampleSize, nil, nil))
+	if file.err != nil || file.binary || file.encoding != "latin-1" {
+		t.Fatalf("Expected a Latin-1 text file, got encoding %q (binary %v, error %v)", file.encoding, file.binary, file.err)
+	}
+	decoded := decodeFile(file, "keep")
+	if !strings.HasSuffix(string(decoded.content), "café") || !utf8.Valid(decoded.content) {
+		t.Errorf("Expected the content to end with café in UTF-8, got %q", decoded.content[len(decoded.content)-8:])
+	}
+}
@@ -0,0 +1,180 @@
+// File: src/redact.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	minSecretLength  = 12  // Minimum length of a value assigned to a secret-looking name to be checked
+	minSecretEntropy = 3.2 // Shannon entropy, in bits per character, above which such a value is a secret
+)
+
+// secretRule detects one type of secret. When the pattern has a capture group, only the first group is redacted,
+// so that the name a secret is assigned to stays readable. check, when set, filters the matched values.
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+	check   func(value string) bool
+}
+
+// builtinSecretRules lists the secrets detected by default.
+var builtinSecretRules = []secretRule{
+	{name: "aws-access-key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)},
+	{name: "aws-secret-key", pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{name: "github-token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: "private-key", pattern: regexp.MustCompile(`-----BEGIN[A-Z ]* PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END[A-Z ]* PRIVATE KEY(?: BLOCK)?-----`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{
+		name:    "secret",
+		pattern: regexp.MustCompile(`(?i)[\w.-]*(?:password|passwd|pwd|secret|token|api_?key)[\w.-]*["']?\s*[:=]\s*["']?([A-Za-z0-9_\-+/=.!@#%^&*~]+)`),
+		check:   isHighEntropySecret,
+	},
+}
+
+// SecretRule is a custom secret detection rule from the configuration file.
+type SecretRule struct {
+	Name    string `yaml:"name" toml:"name"`
+	Pattern string `yaml:"pattern" toml:"pattern"`
+}
+
+// compileSecretRules returns the built-in rules followed by the custom rules of the configuration file.
+func compileSecretRules(custom []SecretRule) ([]secretRule, error) {
+	rules := append([]secretRule(nil), builtinSecretRules...)
+	for _, rule := range custom {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %q has no name", rule.Pattern)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+		rules = append(rules, secretRule{name: rule.Name, pattern: re})
+	}
+	return rules, nil
+}
+
+// isHighEntropySecret reports whether a value assigned to a secret-looking name looks random enough to be a
+// real secret rather than a placeholder or an identifier: it must be long, mix letters and digits, and have
+// a high Shannon entropy.
+func isHighEntropySecret(value string) bool {
+	if len(value) < minSecretLength || !strings.ContainsAny(value, "0123456789") ||
+		!strings.ContainsAny(strings.ToLower(value), "abcdefghijklmnopqrstuvwxyz") {
+		return false
+	}
+	counts := make(map[rune]int)
+	for _, r := range value {
+		counts[r]++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(value))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minSecretEntropy
+}
+
+// secretFinding is a secret detected in a file.
+type secretFinding struct {
+	rule string
+	line int
+}
+
+// secretMatch is the span of a secret in the content of a file.
+type secretMatch struct {
+	start, end int
+	rule       string
+}
+
+// findSecrets returns the secrets in content, in order and without overlaps.
+func findSecrets(content []byte, rules []secretRule) []secretMatch {
+	var matches []secretMatch
+	for _, rule := range rules {
+		for _, loc := range rule.pattern.FindAllSubmatchIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if rule.check != nil && !rule.check(string(content[start:end])) {
+				continue
+			}
+			matches = append(matches, secretMatch{start: start, end: end, rule: rule.name})
+		}
+	}
+
+	// The earliest and then the longest match wins
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+	var kept []secretMatch
+	for _, match := range matches {
+		if len(kept) == 0 || match.start >= kept[len(kept)-1].end {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
+
+// redactFile replaces the secrets in a text file with "[REDACTED:type]" markers and records where they were found.
+func redactFile(file loadedFile, rules []secretRule) loadedFile {
+	if file.err != nil || file.binary || len(rules) == 0 {
+		return file
+	}
+	matches := findSecrets(file.content, rules)
+	if len(matches) == 0 {
+		return file
+	}
+
+	var redacted bytes.Buffer
+	offset, line := 0, 1
+	for _, match := range matches {
+		line += bytes.Count(file.content[offset:match.start], []byte("\n"))
+		file.secrets = append(file.secrets, secretFinding{rule: match.rule, line: line})
+		redacted.Write(file.content[offset:match.start])
+		fmt.Fprintf(&redacted, "[REDACTED:%s]", match.rule)
+		line += bytes.Count(file.content[match.start:match.end], []byte("\n"))
+		offset = match.end
+	}
+	redacted.Write(file.content[offset:])
+	file.content = redacted.Bytes()
+	return file
+}
+
+// describeSecrets lists the secrets found in a file, e.g. "aws-access-key (line 3), jwt (line 10)".
+func describeSecrets(secrets []secretFinding) string {
+	var descriptions []string
+	for _, secret := range secrets {
+		descriptions = append(descriptions, fmt.Sprintf("%s (line %d)", secret.rule, secret.line))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// printSecretReport reports the files in which secrets were found, either redacted or, with -fail-on-secrets,
+// left unwritten.
+func printSecretReport(opts *options, files []loadedFile, redacted bool) {
+	if len(files) == 0 {
+		return
+	}
+	count := 0
+	for _, file := range files {
+		count += len(file.secrets)
+	}
+	if redacted {
+		opts.logf("Redacted %d secrets in %d files:\n", count, len(files))
+	} else {
+		opts.logf("Found %d secrets in %d files:\n", count, len(files))
+	}
+	for _, file := range files {
+		opts.logf("  %s: %s\n", filepath.ToSlash(file.relativePath), describeSecrets(file.secrets))
+	}
+}
@@ -0,0 +1,52 @@
+// File: src/sensitive.go
+
+package main
+
+import "path/filepath"
+
+// defaultSensitiveFiles lists the names and globs of files likely to hold credentials, which are never included
+// unless -allow-sensitive is set. A glob without a slash matches the name of a file at any depth.
+var defaultSensitiveFiles = []string{
+	// SSH and TLS private keys and certificate stores
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519", "*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	// Cloud and service credentials
+	"credentials", "credentials.json", "service-account*.json", "client_secret*.json", ".netrc", ".pgpass", ".htpasswd",
+	// Environment files, but not their templates such as .env.example
+	".env", ".env.local", ".env.*.local", ".env.dev", ".env.development", ".env.prod", ".env.production",
+	".env.staging", ".env.test",
+	// Password databases
+	"*.kdbx",
+}
+
+// walkState is the state shared by the whole walk of the include directories.
+type walkState struct {
+	visited    visitedTargets  // Targets already walked, when symbolic links are followed
+	linked     map[string]bool // Directories walked through a symbolic link
+	superseded []string        // Paths reached through a link whose target was then reached at its real path
+	sensitive  []string        // Relative paths of the sensitive files skipped
+}
+
+// skipsSensitive reports whether the file at relPath matches the sensitive file deny list, logging and
+// recording it so that a warning can list it once the walk is over.
+func (walk *walkState) skipsSensitive(relPath string, opts *options) bool {
+	match := matchGlobs(relPath, opts.sensitiveFiles)
+	if match < 0 {
+		return false
+	}
+	if opts.verbose {
+		opts.logf("Skipping file %s: sensitive file (matches %q)\n", relPath, opts.sensitiveFiles[match].pattern)
+	}
+	walk.sensitive = append(walk.sensitive, relPath)
+	return true
+}
+
+// printSensitiveFiles warns about the sensitive files skipped, so that users know why they are missing.
+func printSensitiveFiles(opts *options, sensitive []string) {
+	if len(sensitive) == 0 {
+		return
+	}
+	opts.logf("Warning: skipped %d sensitive files (use -allow-sensitive to include them):\n", len(sensitive))
+	for _, relPath := range sensitive {
+		opts.logf("  %s\n", filepath.ToSlash(relPath))
+	}
+}
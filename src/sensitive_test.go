@@ -0,0 +1,54 @@
+// File: src/sensitive_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSensitiveFiles checks that files likely to hold credentials are skipped unless -allow-sensitive is set.
+func TestSensitiveFiles(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	for _, path := range []string{".env", ".env.example", "certs/server.pem", "config/credentials.json", "keys/id_rsa", "main.go"} {
+		fullPath := filepath.Join(parentDir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(path+"\n"), 0644)
+	}
+
+	sensitiveFiles, _ := compilePathGlobs(defaultSensitiveFiles, true)
+	tests := []struct {
+		sensitiveFiles []pathGlob
+		expected       string
+	}{
+		{sensitiveFiles, ".env.example,main.go"},
+		{nil, ".env,.env.example,certs/server.pem,config/credentials.json,keys/id_rsa,main.go"},
+	}
+
+	for _, test := range tests {
+		opts := &options{directories: []string{"."}, hidden: true, sensitiveFiles: test.sensitiveFiles}
+		candidates, err := collectFiles(opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var paths []string
+		for _, candidate := range candidates {
+			paths = append(paths, filepath.ToSlash(candidate.relativePath))
+		}
+		if result := strings.Join(paths, ","); result != test.expected {
+			t.Errorf("With %d sensitive globs: expected %s, got %s", len(test.sensitiveFiles), test.expected, result)
+		}
+	}
+
+	walk := &walkState{}
+	opts := &options{sensitiveFiles: sensitiveFiles}
+	for _, relPath := range []string{"deploy/prod.key", "app/.env.production.local", "src/keyboard.go"} {
+		walk.skipsSensitive(filepath.FromSlash(relPath), opts)
+	}
+	if len(walk.sensitive) != 2 || filepath.ToSlash(walk.sensitive[1]) != "app/.env.production.local" {
+		t.Errorf("Expected the key and the local environment file to be recorded, got %v", walk.sensitive)
+	}
+}
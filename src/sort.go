@@ -0,0 +1,157 @@
+// File: src/sort.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortOrders lists the supported values of the -sort flag.
+var sortOrders = []string{"name", "path", "ext", "size", "mtime", "git-recency"}
+
+// isSortOrder reports whether order is a supported sort order. An empty order keeps the walk order.
+func isSortOrder(order string) bool {
+	return order == "" || slices.Contains(sortOrders, order)
+}
+
+// sortFiles orders the files of every include directory together. Files matching a -priority glob come first,
+// in the order of the globs, and the -sort order applies within each group. Files that tie keep their walk order.
+func sortFiles(candidates []fileCandidate, opts *options) {
+	if opts.sortOrder == "" && len(opts.priority) == 0 {
+		return
+	}
+
+	var commitTimes []int64
+	if opts.sortOrder == "git-recency" {
+		commitTimes = lastCommitTimes(candidates, opts)
+	}
+
+	keys := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = len(opts.priority)
+		if match := matchGlobs(candidate.relativePath, opts.priority); match >= 0 {
+			keys[i] = match
+		}
+	}
+
+	indexes := make([]int, len(candidates))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if keys[a] != keys[b] {
+			return keys[a] < keys[b]
+		}
+		return compareBySortOrder(candidates[a], candidates[b], commitTimes, a, b, opts.sortOrder) < 0
+	})
+
+	sorted := make([]fileCandidate, len(candidates))
+	for i, index := range indexes {
+		sorted[i] = candidates[index]
+	}
+	copy(candidates, sorted)
+}
+
+// compareBySortOrder compares two files by the sort order. a and b index the files in commitTimes.
+func compareBySortOrder(x, y fileCandidate, commitTimes []int64, a, b int, order string) int {
+	switch order {
+	case "name":
+		if result := strings.Compare(filepath.Base(x.relativePath), filepath.Base(y.relativePath)); result != 0 {
+			return result
+		}
+		return strings.Compare(filepath.ToSlash(x.relativePath), filepath.ToSlash(y.relativePath))
+	case "path":
+		return strings.Compare(filepath.ToSlash(x.relativePath), filepath.ToSlash(y.relativePath))
+	case "ext":
+		xExt, yExt := strings.ToLower(filepath.Ext(x.relativePath)), strings.ToLower(filepath.Ext(y.relativePath))
+		if result := strings.Compare(xExt, yExt); result != 0 {
+			return result
+		}
+		return strings.Compare(filepath.ToSlash(x.relativePath), filepath.ToSlash(y.relativePath))
+	case "size":
+		// Smallest first
+		return compareInt64(x.size, y.size)
+	case "mtime":
+		// Most recently modified first
+		return y.modTime.Compare(x.modTime)
+	case "git-recency":
+		// Most recently committed first; files never committed come last
+		return compareInt64(commitTimes[b], commitTimes[a])
+	}
+	return 0
+}
+
+// lastCommitTimes returns the time of the last commit touching each file, as a Unix timestamp, or 0 for
+// files outside a git repository or never committed. The history of each repository is read once; a repository
+// whose history cannot be read, because it has no commits or git is not installed, is treated as having none.
+func lastCommitTimes(candidates []fileCandidate, opts *options) []int64 {
+	repoRoots := make(map[string]string)           // Directory to repository root
+	histories := make(map[string]map[string]int64) // Repository root to last commit time of each path
+	times := make([]int64, len(candidates))
+
+	for i, candidate := range candidates {
+		dir := filepath.Dir(candidate.path)
+		root, ok := repoRoots[dir]
+		if !ok {
+			root = findRepoRoot(dir)
+			repoRoots[dir] = root
+		}
+		if root == "" {
+			continue
+		}
+
+		history, ok := histories[root]
+		if !ok {
+			var err error
+			history, err = readGitHistory(root)
+			if err != nil && opts.verbose {
+				opts.logf("Sorting files of %s as never committed: %v\n", root, err)
+			}
+			histories[root] = history
+		}
+		if relPath, err := filepath.Rel(root, candidate.path); err == nil {
+			times[i] = history[filepath.ToSlash(relPath)]
+		}
+	}
+	return times
+}
+
+// readGitHistory maps every path of the repository at root to the time of the last commit touching it.
+func readGitHistory(root string) (map[string]int64, error) {
+	cmd := exec.Command("git", "-C", root, "-c", "core.quotePath=false", "log", "--format=%x00%ct", "--name-only", "--no-renames")
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			return nil, fmt.Errorf("error reading git history: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("error reading git history: %v", err)
+	}
+
+	// The log lists the newest commits first, so the first time a path appears is its last commit
+	history := make(map[string]int64)
+	var commitTime int64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x00") {
+			commitTime, _ = strconv.ParseInt(line[1:], 10, 64)
+			continue
+		}
+		if _, seen := history[line]; line != "" && !seen {
+			history[line] = commitTime
+		}
+	}
+	return history, scanner.Err()
+}
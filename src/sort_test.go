@@ -0,0 +1,113 @@
+// File: src/sort_test.go
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSortFiles checks the sort orders and that priority globs come first, in the order they are given.
+func TestSortFiles(t *testing.T) {
+	newCandidates := func() []fileCandidate {
+		return []fileCandidate{
+			{relativePath: "docs/guide.md", size: 30},
+			{relativePath: "src/main.go", size: 20},
+			{relativePath: "src/util/a.go", size: 10},
+			{relativePath: "README.md", size: 40},
+			{relativePath: "go.mod", size: 5},
+		}
+	}
+	paths := func(candidates []fileCandidate) string {
+		var result []string
+		for _, candidate := range candidates {
+			result = append(result, candidate.relativePath)
+		}
+		return strings.Join(result, ",")
+	}
+
+	priority, _ := compilePathGlobs([]string{"README.md", "go.mod", "main.go"}, true)
+	tests := []struct {
+		order    string
+		priority []pathGlob
+		expected string
+	}{
+		{"name", nil, "README.md,src/util/a.go,go.mod,docs/guide.md,src/main.go"},
+		{"path", nil, "README.md,docs/guide.md,go.mod,src/main.go,src/util/a.go"},
+		{"ext", nil, "src/main.go,src/util/a.go,README.md,docs/guide.md,go.mod"},
+		{"size", nil, "go.mod,src/util/a.go,src/main.go,docs/guide.md,README.md"},
+		{"", priority, "README.md,go.mod,src/main.go,docs/guide.md,src/util/a.go"},
+		{"size", priority[2:], "src/main.go,go.mod,src/util/a.go,docs/guide.md,README.md"},
+	}
+
+	for _, test := range tests {
+		candidates := newCandidates()
+		sortFiles(candidates, &options{sortOrder: test.order, priority: test.priority})
+		if result := paths(candidates); result != test.expected {
+			t.Errorf("Sort %q with %d priority globs: expected %s, got %s", test.order, len(test.priority), test.expected, result)
+		}
+	}
+}
+
+// TestSortFilesGitRecency checks that the most recently committed files come first and uncommitted files last.
+func TestSortFilesGitRecency(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(env []string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	commit := func(name, date string) {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+		git(nil, "add", name)
+		env := []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}
+		git(env, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", name)
+	}
+	git(nil, "init", "-q")
+	commit("old.txt", "2020-01-01T00:00:00Z")
+	commit("new.txt", "2024-01-01T00:00:00Z")
+	os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("untracked"), 0644)
+
+	var candidates []fileCandidate
+	for _, name := range []string{"untracked.txt", "old.txt", "new.txt"} {
+		candidates = append(candidates, fileCandidate{path: filepath.Join(dir, name), relativePath: name})
+	}
+	sortFiles(candidates, &options{sortOrder: "git-recency"})
+	if candidates[0].relativePath != "new.txt" || candidates[1].relativePath != "old.txt" || candidates[2].relativePath != "untracked.txt" {
+		t.Errorf("Expected new.txt, old.txt, untracked.txt, got %v", candidates)
+	}
+}
+
+// TestSortFilesGitRecencyWithoutCommits checks that a repository whose history cannot be read is sorted as if
+// nothing was committed, and that git's own message is reported.
+func TestSortFilesGitRecencyWithoutCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if output, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	var candidates []fileCandidate
+	for _, name := range []string{"b.txt", "a.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+		candidates = append(candidates, fileCandidate{path: filepath.Join(dir, name), relativePath: name})
+	}
+	sortFiles(candidates, &options{sortOrder: "git-recency", verbose: true})
+	if candidates[0].relativePath != "b.txt" || candidates[1].relativePath != "a.txt" {
+		t.Errorf("Expected the walk order b.txt, a.txt, got %v", candidates)
+	}
+
+	if _, err := readGitHistory(dir); err == nil || !strings.Contains(err.Error(), "fatal:") {
+		t.Errorf("Expected git's error message, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// File: src/split.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// chunk is one numbered output file of a split run.
+type chunk struct {
+	entries []fileEntry
+	cost    fileCost
+}
+
+// splitting reports whether the output is split into numbered chunks.
+func (opts *options) splitting() bool {
+	return opts.splitBytes > 0 || opts.splitTokens > 0 || opts.splitFiles > 0
+}
+
+// chunkPath returns the path of the nth chunk of an output, e.g. taco-001.txt for taco.txt.
+func chunkPath(outputFilePath string, n int) string {
+	ext := filepath.Ext(outputFilePath)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(outputFilePath, ext), n, ext)
+}
+
+// chunkIndexPath returns the path of the index listing the chunks of an output, e.g. taco-index.txt for taco.txt.
+func chunkIndexPath(outputFilePath string) string {
+	ext := filepath.Ext(outputFilePath)
+	return strings.TrimSuffix(outputFilePath, ext) + "-index" + ext
+}
+
+// isChunkFile reports whether path is a chunk or the chunk index of the output, so that the
+// chunks of a previous split run are never concatenated.
+func isChunkFile(path, outputFilePath string) bool {
+	if outputFilePath == "" || filepath.Dir(path) != filepath.Dir(outputFilePath) {
+		return false
+	}
+	base := filepath.Base(outputFilePath)
+	ext := filepath.Ext(base)
+	pattern := "^" + regexp.QuoteMeta(strings.TrimSuffix(base, ext)) + `-(\d{3,}|index)` + regexp.QuoteMeta(ext) + "$"
+	matched, _ := regexp.MatchString(pattern, filepath.Base(path))
+	return matched
+}
+
+// chunkWriter packs files into numbered chunks that each stay within the -split-bytes, -split-tokens and
+// -split-files limits, writing every chunk as soon as it is full.
+type chunkWriter struct {
+	opts     *options
+	measure  *costMeasurer
+	overhead fileCost // Cost of the header and footer written in every chunk
+	counter  *tokenCountingFormatter
+	tree     string // Directory tree written in the first chunk
+	current  chunk
+	written  []chunk
+}
+
+// writeChunks writes the candidates to numbered chunks and an index of the files in each chunk.
+// The directory tree, when not empty, is written at the top of the first chunk. It returns the number of chunks written.
+func writeChunks(files []loadedFile, opts *options, tokenizer tokenizer, counter *tokenCountingFormatter, tree string) (int, error) {
+	if opts.splitTokens == 0 {
+		tokenizer = nil
+	}
+	measure, err := newCostMeasurer(opts, tokenizer)
+	if err != nil {
+		return 0, err
+	}
+	w := &chunkWriter{opts: opts, measure: measure, counter: counter, tree: tree}
+	w.overhead = measure.overhead()
+	w.current.cost = w.overhead
+	if tree != "" {
+		treeCost := measure.tree(tree)
+		w.current.cost.bytes += treeCost.bytes
+		w.current.cost.tokens += treeCost.tokens
+	}
+
+	for _, file := range files {
+		opts.logf("Processing %s ... ", file.relativePath)
+		if err := w.add(file.entry()); err != nil {
+			opts.logf("Error\n")
+			opts.logf("Error processing file %s: %v\n", file.relativePath, err)
+			continue
+		}
+		opts.logf("Done\n")
+	}
+	if len(w.current.entries) > 0 {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(w.written) > 0 {
+		if err := w.writeIndex(); err != nil {
+			return 0, err
+		}
+		removeStaleChunks(opts.outputFilePath, len(w.written))
+	}
+	return len(w.written), nil
+}
+
+// removeStaleChunks removes the chunks numbered after the last one written, left over from a previous
+// run that produced more chunks, so that every chunk on disk is listed in the index.
+func removeStaleChunks(outputFilePath string, written int) {
+	for n := written + 1; ; n++ {
+		if err := os.Remove(chunkPath(outputFilePath, n)); err != nil {
+			return
+		}
+	}
+}
+
+// add places a file in the current chunk, starting a new chunk when it does not fit.
+// A file that does not fit even in an empty chunk is split on line boundaries into parts that do.
+func (w *chunkWriter) add(entry fileEntry) error {
+	cost, err := w.measure.entry(entry)
+	if err != nil {
+		return err
+	}
+
+	entries := []fileEntry{entry}
+	costs := []fileCost{cost}
+	if w.exceedsLimit(w.overhead, cost) {
+		entries, costs, err = w.splitEntry(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, entry := range entries {
+		if len(w.current.entries) > 0 && (w.exceedsLimit(w.current.cost, costs[i]) || w.full()) {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+		w.current.entries = append(w.current.entries, entry)
+		w.current.cost.bytes += costs[i].bytes
+		w.current.cost.tokens += costs[i].tokens
+	}
+	return nil
+}
+
+// exceedsLimit reports whether adding a file of the given cost to a chunk of size used exceeds the size limits.
+func (w *chunkWriter) exceedsLimit(used, cost fileCost) bool {
+	if w.opts.splitBytes > 0 && used.bytes+cost.bytes > w.opts.splitBytes {
+		return true
+	}
+	if w.opts.splitTokens > 0 && used.tokens+cost.tokens > w.opts.splitTokens {
+		return true
+	}
+	return false
+}
+
+// full reports whether the current chunk holds as many files as -split-files allows.
+func (w *chunkWriter) full() bool {
+	return w.opts.splitFiles > 0 && len(w.current.entries) >= w.opts.splitFiles
+}
+
+// splitEntry splits a file that exceeds the chunk size limits into parts made of whole lines, each fitting in
+// an empty chunk. The cost of a part is estimated as the cost of its header plus the cost of each of its lines,
+// so a part may be slightly off the limit; a single line longer than the limit becomes a part of its own.
+func (w *chunkWriter) splitEntry(entry fileEntry) ([]fileEntry, []fileCost, error) {
+	// The part label is sized for the largest part count a file is likely to need
+	header := entry
+	header.content = nil
+	header.part = "999/999"
+	fixed, err := w.measure.entry(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parts [][]byte
+	var costs []fileCost
+	var start int
+	used := fixed
+	lines := bytes.SplitAfter(entry.content, []byte("\n"))
+	offset := 0
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		lineEntry := header
+		lineEntry.content = line
+		cost, err := w.measure.entry(lineEntry)
+		if err != nil {
+			return nil, nil, err
+		}
+		cost.bytes -= fixed.bytes
+		cost.tokens -= fixed.tokens
+
+		if offset > start && w.exceedsLimit(w.overhead, fileCost{used.bytes + cost.bytes, used.tokens + cost.tokens}) {
+			parts = append(parts, entry.content[start:offset])
+			costs = append(costs, used)
+			start = offset
+			used = fixed
+		}
+		used.bytes += cost.bytes
+		used.tokens += cost.tokens
+		offset += len(line)
+	}
+	parts = append(parts, entry.content[start:offset])
+	costs = append(costs, used)
+
+	entries := make([]fileEntry, len(parts))
+	for i, content := range parts {
+		entries[i] = entry
+		entries[i].content = content
+		entries[i].part = fmt.Sprintf("%d/%d", i+1, len(parts))
+	}
+	return entries, costs, nil
+}
+
+// flush writes the current chunk to its numbered file and starts a new one.
+func (w *chunkWriter) flush() error {
+	path := chunkPath(w.opts.outputFilePath, len(w.written)+1)
+	formatter, err := newFormatter(w.opts.format, w.opts.templateFile)
+	if err != nil {
+		return err
+	}
+	if w.counter != nil {
+		w.counter.outputFormatter = formatter
+		formatter = w.counter
+	}
+
+	file, err := createTempOutputFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The temporary file is gone once renamed, so this only cleans up after a failure
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	if err := formatter.writeHeader(file); err != nil {
+		return fmt.Errorf("error writing output header: %v", err)
+	}
+	if len(w.written) == 0 && w.tree != "" {
+		if err := formatter.writeTree(file, w.tree); err != nil {
+			return fmt.Errorf("error writing directory tree: %v", err)
+		}
+	}
+	for _, entry := range w.current.entries {
+		if err := formatter.writeFile(file, entry); err != nil {
+			return err
+		}
+	}
+	if err := formatter.writeFooter(file); err != nil {
+		return fmt.Errorf("error writing output footer: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error closing output file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("error replacing output file: %v", err)
+	}
+
+	w.written = append(w.written, w.current)
+	w.current = chunk{cost: w.overhead}
+	return nil
+}
+
+// writeIndex writes the index listing the source files, or file parts, that landed in each chunk.
+func (w *chunkWriter) writeIndex() error {
+	var sb strings.Builder
+	for i, chunk := range w.written {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%s (%d files, %d bytes", filepath.Base(chunkPath(w.opts.outputFilePath, i+1)), len(chunk.entries), chunk.cost.bytes)
+		if w.opts.splitTokens > 0 {
+			fmt.Fprintf(&sb, ", %d tokens", chunk.cost.tokens)
+		}
+		sb.WriteString(")\n")
+		for _, entry := range chunk.entries {
+			fmt.Fprintf(&sb, "  %s%s\n", filepath.ToSlash(entry.path), entry.partSuffix())
+		}
+	}
+
+	path := chunkIndexPath(w.opts.outputFilePath)
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing chunk index %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// File: src/split_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestChunkPaths checks the names of the chunks and of the index, and that both are recognized as chunk files.
+func TestChunkPaths(t *testing.T) {
+	output := filepath.Join("out", "taco.txt")
+	if path := chunkPath(output, 2); path != filepath.Join("out", "taco-002.txt") {
+		t.Errorf("Expected chunk path out/taco-002.txt, got %s", path)
+	}
+	if path := chunkIndexPath(output); path != filepath.Join("out", "taco-index.txt") {
+		t.Errorf("Expected index path out/taco-index.txt, got %s", path)
+	}
+
+	tests := map[string]bool{
+		filepath.Join("out", "taco-001.txt"):   true,
+		filepath.Join("out", "taco-1234.txt"):  true,
+		filepath.Join("out", "taco-index.txt"): true,
+		filepath.Join("out", "taco-01.txt"):    false,
+		filepath.Join("out", "taco-001.md"):    false,
+		filepath.Join("src", "taco-001.txt"):   false,
+	}
+	for path, expected := range tests {
+		if result := isChunkFile(path, output); result != expected {
+			t.Errorf("isChunkFile(%q) = %v; want %v", path, result, expected)
+		}
+	}
+}
+
+// TestConcatenateFilesSplitFiles checks that -split-files writes numbered chunks, an index, and removes stale chunks.
+func TestConcatenateFilesSplitFiles(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	srcDir := filepath.Join(parentDir, "src")
+	os.Mkdir(srcDir, 0755)
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		os.WriteFile(filepath.Join(srcDir, name), []byte("package "+strings.TrimSuffix(name, ".go")+"\n"), 0644)
+	}
+	outputFile := filepath.Join(parentDir, "taco.txt")
+	os.WriteFile(chunkPath(outputFile, 3), []byte("stale"), 0644)
+
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"src"},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		splitFiles:     2,
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	first, _ := os.ReadFile(chunkPath(outputFile, 1))
+	expected := "// File: src/a.go\n\npackage a\n\n// File: src/b.go\n\npackage b\n\n"
+	if string(first) != expected {
+		t.Errorf("Expected first chunk:\n%s\nGot:\n%s", expected, first)
+	}
+	second, _ := os.ReadFile(chunkPath(outputFile, 2))
+	if string(second) != "// File: src/c.go\n\npackage c\n\n" {
+		t.Errorf("Expected second chunk to hold src/c.go, got:\n%s", second)
+	}
+	if _, err := os.Stat(chunkPath(outputFile, 3)); !os.IsNotExist(err) {
+		t.Error("Expected the stale third chunk to be removed")
+	}
+
+	index, _ := os.ReadFile(chunkIndexPath(outputFile))
+	expectedIndex := "taco-001.txt (2 files, 60 bytes)\n  src/a.go\n  src/b.go\n\ntaco-002.txt (1 files, 30 bytes)\n  src/c.go\n"
+	if string(index) != expectedIndex {
+		t.Errorf("Expected index:\n%s\nGot:\n%s", expectedIndex, index)
+	}
+}
+
+// TestSplitLargeFile checks that a file larger than a chunk is split on line boundaries into labeled parts.
+func TestSplitLargeFile(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, strings.Repeat("x", 9))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	os.WriteFile(filepath.Join(parentDir, "big.txt"), []byte(content), 0644)
+	outputFile := filepath.Join(parentDir, "taco.txt")
+
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"."},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		splitBytes:     150,
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	var joined string
+	for n := 1; ; n++ {
+		data, err := os.ReadFile(chunkPath(outputFile, n))
+		if err != nil {
+			if n < 3 {
+				t.Fatalf("Expected at least two chunks, found %d", n-1)
+			}
+			break
+		}
+		if len(data) > 150 {
+			t.Errorf("Chunk %d is %d bytes, over the 150 byte limit", n, len(data))
+		}
+		header, body, _ := strings.Cut(string(data), "\n\n")
+		if !strings.HasPrefix(header, "// File: big.txt (part ") {
+			t.Errorf("Expected a part header in chunk %d, got %q", n, header)
+		}
+		if !strings.HasSuffix(body, "x\n\n") {
+			t.Errorf("Expected chunk %d to end on a line boundary, got %q", n, body)
+		}
+		joined += strings.TrimSuffix(body, "\n")
+	}
+	if joined != content {
+		t.Errorf("Expected the parts to add up to the original content")
+	}
+}
@@ -0,0 +1,143 @@
+// File: src/symlink.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// symlinkModes lists the supported values of the -follow-symlinks flag: skip ignores every symbolic link,
+// files follows links to files only, and all also walks linked directories.
+var symlinkModes = []string{"skip", "files", "all"}
+
+// isSymlinkMode reports whether mode is a supported -follow-symlinks mode.
+func isSymlinkMode(mode string) bool {
+	return slices.Contains(symlinkModes, mode)
+}
+
+// fileID identifies the target of a path, so that a file or directory reached through several paths is walked once.
+type fileID struct {
+	device uint64
+	inode  uint64
+	path   string // Resolved path, used where the platform has no inode numbers
+}
+
+// resolvedID identifies a target by its path with all symbolic links resolved.
+func resolvedID(path string) fileID {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return fileID{path: path}
+}
+
+// visitedTarget is the first path that reached a target, and whether it was reached through a symbolic link.
+type visitedTarget struct {
+	path    string
+	viaLink bool
+}
+
+// visitedTargets maps every directory and file walked to the path that first reached it. Directories are
+// recorded before their entries are walked, so a link back to one of their ancestors is detected as a cycle.
+type visitedTargets map[fileID]visitedTarget
+
+// viaLink reports whether path, whose target is linkTarget if path is a symbolic link, is reached through a link.
+func (walk *walkState) viaLink(path string, linkTarget os.FileInfo) bool {
+	return linkTarget != nil || walk.linked[filepath.Dir(path)]
+}
+
+// seen records the target of path, described by info, and reports whether an earlier path already reached it.
+// viaLink tells whether path is reached through a symbolic link. Only targets reached through a link count as
+// duplicates, so hard links to one file are all kept. A target first reached through a link is kept at its real
+// path once the walk reaches it there: the linked path is superseded and dropped after the walk.
+// Without following symbolic links every target is reached once, and nothing is recorded.
+func (walk *walkState) seen(path string, info os.FileInfo, viaLink bool, opts *options) bool {
+	if walk.visited == nil {
+		return false
+	}
+	id := targetID(path, info)
+	first, seen := walk.visited[id]
+	switch {
+	case !seen:
+	case !first.viaLink && !viaLink:
+		return false
+	case first.viaLink && !viaLink:
+		if opts.verbose && !walk.isSuperseded(first.path) {
+			opts.logf("Skipping %s: same target as %s\n", relativeToWorkingDir(first.path), relativeToWorkingDir(path))
+		}
+		walk.superseded = append(walk.superseded, first.path)
+	default:
+		if opts.verbose {
+			if info.IsDir() && strings.HasPrefix(path, first.path+string(filepath.Separator)) {
+				opts.logf("Skipping symlink %s: cycle back to %s\n", relativeToWorkingDir(path), relativeToWorkingDir(first.path))
+			} else {
+				opts.logf("Skipping %s: same target as %s\n", relativeToWorkingDir(path), relativeToWorkingDir(first.path))
+			}
+		}
+		return true
+	}
+
+	walk.visited[id] = visitedTarget{path: path, viaLink: viaLink}
+	if viaLink && info.IsDir() {
+		walk.linked[path] = true
+	}
+	return false
+}
+
+// isSuperseded reports whether path is, or is inside, a linked path superseded by its real path.
+func (walk *walkState) isSuperseded(path string) bool {
+	for _, superseded := range walk.superseded {
+		if path == superseded || strings.HasPrefix(path, superseded+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropSuperseded removes the files reached through a linked path that was superseded by its real path.
+func (walk *walkState) dropSuperseded(candidates []fileCandidate) []fileCandidate {
+	if len(walk.superseded) == 0 {
+		return candidates
+	}
+	kept := candidates[:0]
+	for _, candidate := range candidates {
+		if !walk.isSuperseded(candidate.path) {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// followsSymlink applies the -follow-symlinks mode to the symbolic link at path, whose target is described by
+// target, and reports whether the walk follows it.
+func (opts *options) followsSymlink(path string, target os.FileInfo) bool {
+	switch {
+	case opts.followSymlinks == "skip":
+		if opts.verbose {
+			opts.logf("Skipping symlink %s: symlinks are not followed\n", relativeToWorkingDir(path))
+		}
+		return false
+	case target.IsDir() && opts.followSymlinks != "all":
+		if opts.verbose {
+			opts.logf("Skipping symlink %s: links to directories are only followed with -follow-symlinks=all\n", relativeToWorkingDir(path))
+		}
+		return false
+	}
+
+	if opts.verbose {
+		destination, _ := os.Readlink(path)
+		opts.logf("Following symlink %s -> %s\n", relativeToWorkingDir(path), destination)
+	}
+	return true
+}
+
+// relativeToWorkingDir returns path relative to the working directory, or path itself if it has none.
+func relativeToWorkingDir(path string) string {
+	relPath, err := filepath.Rel(initialWorkingDir, path)
+	if err != nil {
+		return path
+	}
+	return relPath
+}
@@ -0,0 +1,111 @@
+// File: src/symlink_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFollowSymlinks checks each -follow-symlinks mode, including cycles, broken links and targets reached twice.
+func TestFollowSymlinks(t *testing.T) {
+	parentDir := t.TempDir()
+	rootDir := filepath.Join(parentDir, "root")
+	outsideDir := filepath.Join(parentDir, "outside")
+	os.MkdirAll(filepath.Join(rootDir, "alpha"), 0755)
+	os.MkdirAll(outsideDir, 0755)
+	os.WriteFile(filepath.Join(rootDir, "alpha", "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(outsideDir, "b.txt"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(outsideDir, "c.txt"), []byte("c"), 0644)
+
+	links := map[string]string{
+		"alpha/loop": "..",                               // Cycle back to the root
+		"broken.txt": "missing.txt",                      // Broken link
+		"ext":        outsideDir,                         // Directory outside the root
+		"ext.txt":    filepath.Join(outsideDir, "c.txt"), // File also reached through ext
+		"zlink.txt":  filepath.Join("alpha", "a.txt"),    // File also reached directly
+		"zlinkdir":   "alpha",                            // Directory also reached directly
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(rootDir, filepath.FromSlash(link))); err != nil {
+			t.Skipf("Symbolic links are not supported: %v", err)
+		}
+	}
+	initialWorkingDir = rootDir
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"skip", "alpha/a.txt"},
+		{"files", "alpha/a.txt,ext.txt"},
+		{"all", "alpha/a.txt,ext/b.txt,ext/c.txt"},
+	}
+
+	for _, test := range tests {
+		candidates, err := collectFiles(&options{directories: []string{"."}, followSymlinks: test.mode, verbose: true})
+		if err != nil {
+			t.Fatalf("Mode %s: unexpected error: %v", test.mode, err)
+		}
+		var paths []string
+		for _, candidate := range candidates {
+			paths = append(paths, filepath.ToSlash(candidate.relativePath))
+		}
+		if result := strings.Join(paths, ","); result != test.expected {
+			t.Errorf("Mode %s: expected %s, got %s", test.mode, test.expected, result)
+		}
+	}
+}
+
+// TestFollowSymlinksKeepsRealPath checks that a target reached through a link before its real path is kept
+// at its real path.
+func TestFollowSymlinksKeepsRealPath(t *testing.T) {
+	rootDir := t.TempDir()
+	os.MkdirAll(filepath.Join(rootDir, "d"), 0755)
+	os.WriteFile(filepath.Join(rootDir, "d", "a.go"), []byte("a"), 0644)
+	for link, target := range map[string]string{"b.go": filepath.Join("d", "a.go"), "c": "d"} {
+		if err := os.Symlink(target, filepath.Join(rootDir, link)); err != nil {
+			t.Skipf("Symbolic links are not supported: %v", err)
+		}
+	}
+	initialWorkingDir = rootDir
+
+	for _, mode := range []string{"files", "all"} {
+		candidates, err := collectFiles(&options{directories: []string{"."}, followSymlinks: mode, verbose: true})
+		if err != nil {
+			t.Fatalf("Mode %s: unexpected error: %v", mode, err)
+		}
+		if len(candidates) != 1 || filepath.ToSlash(candidates[0].relativePath) != "d/a.go" {
+			t.Errorf("Mode %s: expected only d/a.go, got %v", mode, candidates)
+		}
+	}
+}
+
+// TestFollowSymlinksKeepsHardLinks checks that files sharing an inode without any symbolic link involved are
+// all kept, while a symbolic link to one of them is still a duplicate.
+func TestFollowSymlinksKeepsHardLinks(t *testing.T) {
+	rootDir := t.TempDir()
+	os.MkdirAll(filepath.Join(rootDir, "real"), 0755)
+	os.WriteFile(filepath.Join(rootDir, "real", "a.txt"), []byte("a"), 0644)
+	if err := os.Link(filepath.Join(rootDir, "real", "a.txt"), filepath.Join(rootDir, "hard.txt")); err != nil {
+		t.Skipf("Hard links are not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("real", "a.txt"), filepath.Join(rootDir, "soft.txt")); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+	initialWorkingDir = rootDir
+
+	candidates, err := collectFiles(&options{directories: []string{"."}, followSymlinks: "files", verbose: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var paths []string
+	for _, candidate := range candidates {
+		paths = append(paths, filepath.ToSlash(candidate.relativePath))
+	}
+	if result := strings.Join(paths, ","); result != "hard.txt,real/a.txt" {
+		t.Errorf("Expected hard.txt,real/a.txt, got %s", result)
+	}
+}
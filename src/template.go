@@ -0,0 +1,174 @@
+// File: src/template.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFile is the data available to the "file" section of an output template.
+type templateFile struct {
+	Path     string    // Absolute path of the file
+	RelPath  string    // Path relative to the working directory, with forward slashes
+	Ext      string    // Extension including the dot, e.g. ".go"
+	Language string    // Language inferred from the file name, or ""
+	Size     int       // Size of the content in bytes
+	Lines    int       // Number of lines in the content
+	ModTime  time.Time // Last modification time
+	Part     string    // Part number and count, e.g. "2/3", when the file is split across output chunks, or ""
+	Encoding string    // Original encoding, e.g. "latin-1", when the file was transcoded to UTF-8, or ""
+	Content  string
+}
+
+// templateSummary is the data available to the "header", "tree" and "footer" sections of an output template.
+// Files and Size are only known once every file has been written, so they are zero in the header.
+type templateSummary struct {
+	WorkingDir string
+	Date       time.Time
+	Files      int
+	Size       int
+	Tree       string // Directory tree of the included files when -tree is set, or ""
+}
+
+// templateFormatter renders the output with a user-defined text/template. The template file may define
+// "header", "tree", "file" and "footer" templates; when it defines no "file" template, its body is used for each file.
+type templateFormatter struct {
+	tmpl    *template.Template
+	summary templateSummary
+}
+
+// newTemplateFormatter parses the template file at path.
+func newTemplateFormatter(path string) (*templateFormatter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template file %s: %v", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template file %s: %v", path, err)
+	}
+	if tmpl.Lookup("file") != nil {
+		tmpl = tmpl.Lookup("file")
+	}
+	return &templateFormatter{
+		tmpl:    tmpl,
+		summary: templateSummary{WorkingDir: initialWorkingDir, Date: time.Now()},
+	}, nil
+}
+
+func (f *templateFormatter) writeHeader(w io.Writer) error {
+	return f.executeOptional(w, "header", f.summary)
+}
+
+func (f *templateFormatter) writeTree(w io.Writer, tree string) error {
+	f.summary.Tree = tree
+	return f.executeOptional(w, "tree", f.summary)
+}
+
+func (f *templateFormatter) writeFile(w io.Writer, file fileEntry) error {
+	data := templateFile{
+		Path:     file.absPath,
+		RelPath:  filepath.ToSlash(file.path),
+		Ext:      filepath.Ext(file.path),
+		Language: languageForFile(file.path),
+		Size:     len(file.content),
+		Lines:    countLines(file.content),
+		ModTime:  file.modTime,
+		Part:     file.part,
+		Encoding: file.encoding,
+		Content:  string(file.content),
+	}
+	if err := f.tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("error rendering template for %s: %v", file.path, err)
+	}
+	f.summary.Files++
+	f.summary.Size += len(file.content)
+	return nil
+}
+
+func (f *templateFormatter) writeFooter(w io.Writer) error {
+	return f.executeOptional(w, "footer", f.summary)
+}
+
+// executeOptional renders the named template if the template file defines it.
+func (f *templateFormatter) executeOptional(w io.Writer, name string, data interface{}) error {
+	if f.tmpl.Lookup(name) == nil {
+		return nil
+	}
+	if err := f.tmpl.ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("error rendering %s template: %v", name, err)
+	}
+	return nil
+}
+
+// templateFuncs are the helper functions available in output templates.
+var templateFuncs = template.FuncMap{
+	"indent":  indentLines,
+	"trim":    strings.TrimSpace,
+	"comment": commentLines,
+}
+
+// indentLines prefixes every non-empty line of text with n spaces.
+func indentLines(n int, text string) string {
+	prefix := strings.Repeat(" ", n)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentLines turns text into a comment using the comment style of the language of ext.
+// Line comments are applied to every line; languages with only block comments get a single block.
+func commentLines(ext, text string) string {
+	style := commentStyleForExtension(ext)
+	if style.suffix != "" {
+		return style.prefix + " " + text + " " + style.suffix
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = style.prefix + " " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentStyle describes how a language writes comments. suffix is only set for block-only comment styles.
+type commentStyle struct {
+	prefix string
+	suffix string
+}
+
+// commentStyleForExtension returns the comment style for a file extension, defaulting to "//".
+func commentStyleForExtension(ext string) commentStyle {
+	switch strings.ToLower(ext) {
+	case ".py", ".rb", ".sh", ".bash", ".zsh", ".yaml", ".yml", ".toml", ".r", ".pl", ".ps1", ".ex", ".exs", ".tf", ".dockerfile", ".mk", ".cmake", ".conf", ".ini", ".gitignore":
+		return commentStyle{prefix: "#"}
+	case ".sql", ".lua", ".hs":
+		return commentStyle{prefix: "--"}
+	case ".clj", ".lisp", ".el":
+		return commentStyle{prefix: ";;"}
+	case ".md", ".html", ".htm", ".xml", ".svg", ".vue", ".svelte":
+		return commentStyle{prefix: "<!--", suffix: "-->"}
+	case ".css":
+		return commentStyle{prefix: "/*", suffix: "*/"}
+	}
+	return commentStyle{prefix: "//"}
+}
+
+// countLines returns the number of lines in content, counting a final line without a trailing newline.
+func countLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
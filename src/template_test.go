@@ -0,0 +1,64 @@
+// File: src/template_test.go
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTemplateFormatter checks that the header, file and footer sections are rendered with the file data and helpers.
+func TestTemplateFormatter(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "prompt.tmpl")
+	os.WriteFile(templatePath, []byte(`{{define "header"}}<files>
+{{end}}{{define "file"}}{{comment .Ext (printf "%s (%s, %d lines, %d bytes)" .RelPath .Language .Lines .Size)}}
+{{indent 2 (trim .Content)}}
+{{end}}{{define "footer"}}</files> {{.Files}} files
+{{end}}`), 0644)
+
+	formatter, err := newFormatter("plain", templatePath)
+	if err != nil {
+		t.Fatalf("Error loading template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter.writeHeader(&buf)
+	formatter.writeFile(&buf, fileEntry{path: "src/main.go", content: []byte("package main\n\nfunc main() {}\n")})
+	formatter.writeFile(&buf, fileEntry{path: "run.py", content: []byte("print(1)")})
+	formatter.writeFooter(&buf)
+
+	expected := "<files>\n" +
+		"// src/main.go (go, 3 lines, 29 bytes)\n  package main\n\n  func main() {}\n" +
+		"# run.py (python, 1 lines, 8 bytes)\n  print(1)\n" +
+		"</files> 2 files\n"
+	if buf.String() != expected {
+		t.Errorf("Expected rendered output:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+// TestTemplateFormatterBody checks that a template without a "file" definition is used as the file section.
+func TestTemplateFormatterBody(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "simple.tmpl")
+	os.WriteFile(templatePath, []byte("=== {{.RelPath}} ===\n{{.Content}}\n"), 0644)
+
+	formatter, err := newFormatter("", templatePath)
+	if err != nil {
+		t.Fatalf("Error loading template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter.writeHeader(&buf)
+	formatter.writeFile(&buf, fileEntry{path: "a.txt", content: []byte("hello")})
+	formatter.writeFooter(&buf)
+
+	if buf.String() != "=== a.txt ===\nhello\n" {
+		t.Errorf("Unexpected rendered output:\n%s", buf.String())
+	}
+
+	os.WriteFile(templatePath, []byte("{{.Missing"), 0644)
+	if _, err := newFormatter("", templatePath); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+}
@@ -0,0 +1,147 @@
+// File: src/tokens.go
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// tokenizerNames lists the supported values of the -tokenizer flag.
+var tokenizerNames = []string{"o200k", "cl100k", "estimate"}
+
+// tokenizer counts the tokens a language model would see for a text.
+type tokenizer interface {
+	countTokens(text string) int
+}
+
+// newTokenizer returns the tokenizer for the given -tokenizer value. The BPE vocabularies are embedded in the
+// binary, so no network access is needed.
+func newTokenizer(name string) (tokenizer, error) {
+	var encoding string
+	switch name {
+	case "o200k", "o200k_base":
+		encoding = tiktoken.MODEL_O200K_BASE
+	case "cl100k", "cl100k_base":
+		encoding = tiktoken.MODEL_CL100K_BASE
+	case "estimate":
+		return estimateTokenizer{}, nil
+	default:
+		return nil, fmt.Errorf("Invalid tokenizer %q: expected one of %s", name, strings.Join(tokenizerNames, ", "))
+	}
+
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	encoder, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s tokenizer: %v", name, err)
+	}
+	return bpeTokenizer{encoder: encoder}, nil
+}
+
+// bpeTokenizer counts tokens exactly with a byte-pair encoding vocabulary. Special tokens are counted as plain text.
+type bpeTokenizer struct {
+	encoder *tiktoken.Tiktoken
+}
+
+func (t bpeTokenizer) countTokens(text string) int {
+	return len(t.encoder.EncodeOrdinary(text))
+}
+
+// estimateTokenizer approximates the token count as one token per four characters, which is fast and
+// close enough for English text and source code.
+type estimateTokenizer struct{}
+
+func (estimateTokenizer) countTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// fileTokenCount is the number of tokens a file takes in the output, including its header.
+type fileTokenCount struct {
+	path   string
+	tokens int
+}
+
+// tokenCountingFormatter wraps a formatter and counts the tokens of everything it writes.
+// Tokens of the header, tree and footer are not attributed to any file but are part of the total.
+type tokenCountingFormatter struct {
+	outputFormatter
+	tokenizer tokenizer
+	files     []fileTokenCount
+	overhead  int
+}
+
+func (f *tokenCountingFormatter) writeHeader(w io.Writer) error {
+	return f.count(w, nil, f.outputFormatter.writeHeader)
+}
+
+func (f *tokenCountingFormatter) writeTree(w io.Writer, tree string) error {
+	return f.count(w, nil, func(buf io.Writer) error {
+		return f.outputFormatter.writeTree(buf, tree)
+	})
+}
+
+func (f *tokenCountingFormatter) writeFile(w io.Writer, file fileEntry) error {
+	return f.count(w, &file, func(buf io.Writer) error {
+		return f.outputFormatter.writeFile(buf, file)
+	})
+}
+
+func (f *tokenCountingFormatter) writeFooter(w io.Writer) error {
+	return f.count(w, nil, f.outputFormatter.writeFooter)
+}
+
+// count renders into a buffer with write, records its tokens for file (or as overhead when file is nil),
+// and copies the rendered bytes to w.
+func (f *tokenCountingFormatter) count(w io.Writer, file *fileEntry, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	tokens := f.tokenizer.countTokens(buf.String())
+	if file != nil {
+		f.files = append(f.files, fileTokenCount{path: file.path + file.partSuffix(), tokens: tokens})
+	} else {
+		f.overhead += tokens
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// total returns the number of tokens of the whole output.
+func (f *tokenCountingFormatter) total() int {
+	total := f.overhead
+	for _, file := range f.files {
+		total += file.tokens
+	}
+	return total
+}
+
+// printTokenReport prints the token count of each file, largest first, followed by the total.
+func printTokenReport(opts *options, counter *tokenCountingFormatter) {
+	files := append([]fileTokenCount(nil), counter.files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].tokens != files[j].tokens {
+			return files[i].tokens > files[j].tokens
+		}
+		return files[i].path < files[j].path
+	})
+
+	total := counter.total()
+	opts.logf("\nToken counts (%s tokenizer):\n", opts.tokenizerName)
+	for _, file := range files {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(file.tokens) * 100 / float64(total)
+		}
+		opts.logf("%10d  %5.1f%%  %s\n", file.tokens, percent, filepath.ToSlash(file.path))
+	}
+	opts.logf("%10d  %5.1f%%  total (%d files)\n", total, 100.0, len(files))
+}
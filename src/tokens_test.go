@@ -0,0 +1,57 @@
+// File: src/tokens_test.go
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestTokenizers checks the built-in tokenizers against known token counts.
+func TestTokenizers(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"cl100k", "hello world", 2},
+		{"o200k", "hello world", 2},
+		{"estimate", "hello world", 3},
+		{"estimate", "", 0},
+	}
+
+	for _, test := range tests {
+		tok, err := newTokenizer(test.name)
+		if err != nil {
+			t.Fatalf("Error loading tokenizer %s: %v", test.name, err)
+		}
+		if count := tok.countTokens(test.text); count != test.expected {
+			t.Errorf("Tokenizer %s: expected %d tokens for %q, got %d", test.name, test.expected, test.text, count)
+		}
+	}
+
+	if _, err := newTokenizer("gpt2"); err == nil {
+		t.Error("Expected an error for an unknown tokenizer")
+	}
+}
+
+// TestTokenCountingFormatter checks that tokens are attributed to each file and header output counts toward the total.
+func TestTokenCountingFormatter(t *testing.T) {
+	counter := &tokenCountingFormatter{outputFormatter: xmlFormatter{}, tokenizer: estimateTokenizer{}}
+
+	var buf bytes.Buffer
+	counter.writeHeader(&buf)
+	counter.writeFile(&buf, fileEntry{path: "a.txt", content: []byte("aaaa")})
+	counter.writeFile(&buf, fileEntry{path: "b.txt", content: []byte("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")})
+	counter.writeFooter(&buf)
+
+	if len(counter.files) != 2 || counter.files[0].path != "a.txt" || counter.files[1].tokens <= counter.files[0].tokens {
+		t.Errorf("Unexpected per-file counts: %+v", counter.files)
+	}
+	if counter.overhead == 0 || counter.total() != counter.overhead+counter.files[0].tokens+counter.files[1].tokens {
+		t.Errorf("Expected header and footer tokens to be counted as overhead in the total, got overhead %d and total %d", counter.overhead, counter.total())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("bbbb")) {
+		t.Errorf("Expected the wrapped formatter output to be written through, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,100 @@
+// File: src/tree.go
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// treeNode is a file or directory of the directory tree. Directories add up the sizes and tokens of their files.
+type treeNode struct {
+	name     string
+	children map[string]*treeNode
+	size     int64
+	tokens   int
+}
+
+// buildTree renders an ASCII tree of the files that will be written, rooted at the working directory.
+// With -tree-sizes and -tree-tokens each entry is annotated with its size and token count.
+func buildTree(files []loadedFile, opts *options, tokenizer tokenizer) string {
+	root := &treeNode{name: ".", children: make(map[string]*treeNode)}
+	for _, file := range files {
+		size := int64(len(file.content))
+		var tokens int
+		if opts.treeTokens {
+			tokens = tokenizer.countTokens(string(file.content))
+		}
+
+		node := root
+		node.size += size
+		node.tokens += tokens
+		for _, name := range strings.Split(filepath.ToSlash(file.relativePath), "/") {
+			child, ok := node.children[name]
+			if !ok {
+				child = &treeNode{name: name, children: make(map[string]*treeNode)}
+				node.children[name] = child
+			}
+			child.size += size
+			child.tokens += tokens
+			node = child
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(root.name + root.details(opts) + "\n")
+	root.writeChildren(&sb, "", opts)
+	return sb.String()
+}
+
+// writeChildren writes the children of the node in name order, each line prefixed with the branches above it.
+func (node *treeNode) writeChildren(sb *strings.Builder, prefix string, opts *options) {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		child := node.children[name]
+		branch, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, indent = "└── ", "    "
+		}
+		sb.WriteString(prefix + branch + child.name + child.details(opts) + "\n")
+		child.writeChildren(sb, prefix+indent, opts)
+	}
+}
+
+// details returns the size and token annotations of the node, e.g. " (1.2 KB, 340 tokens)", or "".
+func (node *treeNode) details(opts *options) string {
+	var details []string
+	if opts.treeSizes {
+		details = append(details, formatSize(node.size))
+	}
+	if opts.treeTokens {
+		details = append(details, fmt.Sprintf("%d tokens", node.tokens))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. "512 B" or "1.5 KB".
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit || suffix == "GB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return ""
+}
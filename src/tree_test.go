@@ -0,0 +1,88 @@
+// File: src/tree_test.go
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestBuildTree checks the layout of the tree and the size and token annotations of files and directories.
+func TestBuildTree(t *testing.T) {
+	files := []loadedFile{
+		{fileCandidate: fileCandidate{relativePath: "README.md"}, content: make([]byte, 100)},
+		{fileCandidate: fileCandidate{relativePath: filepath.Join("src", "main.go")}, content: make([]byte, 2048)},
+		{fileCandidate: fileCandidate{relativePath: filepath.Join("src", "util", "strings.go")}, content: make([]byte, 10)},
+		{fileCandidate: fileCandidate{relativePath: filepath.Join("src", "zz.go")}, content: make([]byte, 1)},
+	}
+
+	tree := buildTree(files, &options{}, nil)
+	expected := ".\n" +
+		"├── README.md\n" +
+		"└── src\n" +
+		"    ├── main.go\n" +
+		"    ├── util\n" +
+		"    │   └── strings.go\n" +
+		"    └── zz.go\n"
+	if tree != expected {
+		t.Errorf("Expected tree:\n%s\nGot:\n%s", expected, tree)
+	}
+
+	tree = buildTree(files[:2], &options{treeSizes: true}, nil)
+	expected = ". (2.1 KB)\n├── README.md (100 B)\n└── src (2.0 KB)\n    └── main.go (2.0 KB)\n"
+	if tree != expected {
+		t.Errorf("Expected tree with sizes:\n%s\nGot:\n%s", expected, tree)
+	}
+}
+
+// TestConcatenateFilesTreeOnly checks that -tree-only writes the tree of the included files and nothing else.
+func TestConcatenateFilesTreeOnly(t *testing.T) {
+	parentDir := t.TempDir()
+	initialWorkingDir = parentDir
+	os.Mkdir(filepath.Join(parentDir, "src"), 0755)
+	os.WriteFile(filepath.Join(parentDir, "src", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(parentDir, "src", "notes.log"), []byte("skipped\n"), 0644)
+	outputFile := filepath.Join(parentDir, "output.txt")
+
+	opts := &options{
+		outputFilePath: outputFile,
+		directories:    []string{"src"},
+		excludedPaths:  map[string]struct{}{outputFile: {}},
+		excludeExts:    []string{".log"},
+		tree:           true,
+		treeOnly:       true,
+	}
+	if err := concatenateFiles(opts); err != nil {
+		t.Fatalf("Error concatenating files: %v", err)
+	}
+
+	data, _ := os.ReadFile(outputFile)
+	expected := "// Directory Tree\n\n.\n└── src\n    └── main.go\n\n"
+	if string(data) != expected {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, data)
+	}
+}
+
+// TestJSONFormatterTree checks that the tree is written as a "tree" key before the files array.
+func TestJSONFormatterTree(t *testing.T) {
+	var buf bytes.Buffer
+	formatter, _ := newFormatter("json", "")
+	formatter.writeHeader(&buf)
+	formatter.writeTree(&buf, ".\n└── main.go\n")
+	formatter.writeFile(&buf, fileEntry{path: "main.go", content: []byte("package main\n")})
+	formatter.writeFooter(&buf)
+
+	var doc struct {
+		Tree  string     `json:"tree"`
+		Files []jsonFile `json:"files"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got error %v:\n%s", err, buf.String())
+	}
+	if doc.Tree != ".\n└── main.go\n" || len(doc.Files) != 1 {
+		t.Errorf("Expected the tree and one file, got %+v", doc)
+	}
+}